@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/torrent-viewer/backend/router"
+)
+
+// Users returns a Guard authenticating the given users by the Username and
+// Password headers. users maps the usernames to the passwords.
+func Users(users map[string]string) router.Guard {
+	return func(r *http.Request) (string, bool) {
+		usernames, passwords := r.Header["Username"], r.Header["Password"]
+		if len(usernames) != 1 || len(passwords) != 1 {
+			return "", false
+		}
+		password, ok := users[usernames[0]]
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(passwords[0])) != 1 {
+			return "", false
+		}
+		return usernames[0], true
+	}
+}
+
+// ParseUsers parses a comma-separated list of username:password pairs
+func ParseUsers(list string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, pair := range strings.Split(list, ",") {
+		i := strings.Index(pair, ":")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid user %q, expected username:password", pair)
+		}
+		users[pair[:i]] = pair[i+1:]
+	}
+	return users, nil
+}
+
+// Restrict returns a Guard only authenticating the given principals among
+// the ones authenticated by guard
+func Restrict(guard router.Guard, principals ...string) router.Guard {
+	allowed := map[string]bool{}
+	for _, principal := range principals {
+		allowed[principal] = true
+	}
+	return func(r *http.Request) (string, bool) {
+		principal, authenticated := guard(r)
+		if !authenticated || !allowed[principal] {
+			return "", false
+		}
+		return principal, true
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestrict(t *testing.T) {
+	guard := Restrict(func(r *http.Request) (string, bool) {
+		username := r.Header.Get("Username")
+		return username, username != ""
+	}, "admin", "root")
+
+	tests := []struct {
+		username      string
+		authenticated bool
+	}{
+		{"admin", true},
+		{"root", true},
+		{"alice", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("DELETE", "/shows/1/purge", nil)
+		req.Header.Set("Username", test.username)
+		principal, authenticated := guard(req)
+		if authenticated != test.authenticated {
+			t.Errorf("Expected %q to be authenticated: %t, got %t", test.username, test.authenticated, authenticated)
+		}
+		if authenticated && principal != test.username {
+			t.Errorf("Expected principal %q, got %q", test.username, principal)
+		}
+	}
+}
+
+func TestUsers(t *testing.T) {
+	users, err := ParseUsers("alice:secret,bob:p:ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := Users(users)
+	tests := []struct {
+		username      string
+		password      string
+		authenticated bool
+	}{
+		{"alice", "secret", true},
+		{"bob", "p:ss", true},
+		{"alice", "p:ss", false},
+		{"carol", "secret", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/me/watchlist", nil)
+		if test.username != "" {
+			req.Header.Set("Username", test.username)
+			req.Header.Set("Password", test.password)
+		}
+		principal, authenticated := guard(req)
+		if authenticated != test.authenticated {
+			t.Errorf("Expected %q to be authenticated: %t, got %t", test.username, test.authenticated, authenticated)
+		}
+		if authenticated && principal != test.username {
+			t.Errorf("Expected principal %q, got %q", test.username, principal)
+		}
+	}
+	for _, invalid := range []string{"alice", "alice:", ":secret", "alice:secret,"} {
+		if _, err := ParseUsers(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
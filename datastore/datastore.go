@@ -2,6 +2,8 @@ package datastore
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/jinzhu/gorm"
 	// Initialize MySQL driver
@@ -18,6 +20,14 @@ type Identifiable interface {
 	GetID() int
 }
 
+// Versioned represent an entity whose version is incremented on each update.
+// Updates of a versioned entity fail if it was modified since it was fetched.
+type Versioned interface {
+	Identifiable
+	GetVersion() int
+	SetVersion(version int)
+}
+
 // Init initializes the database connection
 func Init(driver string, user string, password string, host string, port string, database string) error {
 	var dbURI string
@@ -36,12 +46,33 @@ func Init(driver string, user string, password string, host string, port string,
 	return nil
 }
 
-// CountEntities count entities from the datastore with the given constraints
-func CountEntities(model interface{}, out interface{}, where interface{}, args ...interface{}) *herr.Error {
-	conn := Conn.Model(model)
-	if where != nil {
-		conn = conn.Where(where, args...)
+// Scope restricts the set of entities affected by a datastore query
+type Scope func(*gorm.DB) *gorm.DB
+
+// Where is a Scope restricting entities to the ones matching the given conditions
+func Where(query interface{}, args ...interface{}) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
 	}
+}
+
+// Order is a Scope sorting entities by the given SQL expression
+func Order(value string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order(value)
+	}
+}
+
+func scoped(db *gorm.DB, scopes []Scope) *gorm.DB {
+	for _, scope := range scopes {
+		db = scope(db)
+	}
+	return db
+}
+
+// CountEntities count entities from the datastore with the given constraints
+func CountEntities(model interface{}, out interface{}, scopes ...Scope) *herr.Error {
+	conn := scoped(Conn.Model(model), scopes)
 	if err := conn.Count(out).Error; err != nil {
 		return &herr.Error{
 			ID:     "database-error",
@@ -66,9 +97,9 @@ func FetchEntities(out interface{}, where ...interface{}) *herr.Error {
 	return nil
 }
 
-// FetchEntities fetch entities from the datastore with the given constraints
-func FetchPagedEntities(out interface{}, limit int, offset int, where ...interface{}) *herr.Error {
-	if err := Conn.Limit(limit).Offset(offset).Find(out, where...).Error; err != nil {
+// FetchPagedEntities fetch a page of entities from the datastore with the given constraints
+func FetchPagedEntities(out interface{}, limit int, offset int, scopes ...Scope) *herr.Error {
+	if err := scoped(Conn, scopes).Limit(limit).Offset(offset).Find(out).Error; err != nil {
 		return &herr.Error{
 			ID:     "database-error",
 			Status: "500",
@@ -81,7 +112,11 @@ func FetchPagedEntities(out interface{}, limit int, offset int, where ...interfa
 
 // FetchEntity fetch an entity based on its ID
 func FetchEntity(out interface{}, id int) *herr.Error {
-	d := Conn.First(out, id)
+	return fetchEntity(Conn, out, id)
+}
+
+func fetchEntity(db *gorm.DB, out interface{}, id int) *herr.Error {
+	d := db.First(out, id)
 	if d.RecordNotFound() != false {
 		err := d.Error
 		return &herr.Error{
@@ -103,11 +138,17 @@ func FetchEntity(out interface{}, id int) *herr.Error {
 
 // StoreEntity store a new entity in the datastore.
 // The stored entity is not allowed to specify an ID.
-func StoreEntity(in interface{}) *herr.Error {
+func StoreEntity(in interface{}, by Actor) *herr.Error {
 	if Conn.NewRecord(in) != true {
 		return &herr.DuplicateEntryError;
 	}
+	if v, ok := in.(Versioned); ok {
+		v.SetVersion(1)
+	}
 	if err := Conn.Create(in).Error; err != nil {
+		if isUniqueViolation(err) {
+			return &herr.ConflictingEntryError
+		}
 		return &herr.Error{
 			ID:     "database-error",
 			Status: "500",
@@ -115,25 +156,59 @@ func StoreEntity(in interface{}) *herr.Error {
 			Detail: err.Error(),
 		}
 	}
+	notify("create", in, nil, in, by)
 	return nil
 }
 
-// UpdateEntity update an entity in the datastore
-func UpdateEntity(in interface{}) *herr.Error {
-	if err := Conn.Model(in).Update(in).Error; err != nil {
-		return &herr.Error{
+// UpdateEntity update the given fields of an entity in the datastore,
+// including the ones set to their zero value.
+func UpdateEntity(in Identifiable, fields []string, by Actor) *herr.Error {
+	m, err := updateEntity(Conn, in, fields, by)
+	if err != nil {
+		return err
+	}
+	publish(m)
+	return nil
+}
+
+// updateEntity updates an entity through db, and returns the mutation to
+// notify once the update is committed
+func updateEntity(db *gorm.DB, in Identifiable, fields []string, by Actor) (*Mutation, *herr.Error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	before := snapshot(db, in)
+	v := reflect.Indirect(reflect.ValueOf(in))
+	attrs := make(map[string]interface{})
+	for _, field := range fields {
+		attrs[field] = v.FieldByName(field).Interface()
+	}
+	conn := db.Model(in)
+	if versioned, ok := in.(Versioned); ok {
+		conn = conn.Where("version = ?", versioned.GetVersion())
+		attrs["Version"] = versioned.GetVersion() + 1
+	}
+	d := conn.Updates(attrs)
+	if err := d.Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, &herr.ConflictingEntryError
+		}
+		return nil, &herr.Error{
 			ID:     "database-error",
 			Status: "500",
 			Title:  "Database Error",
 			Detail: err.Error(),
 		}
 	}
-	return nil
+	if _, ok := in.(Versioned); ok && d.RowsAffected == 0 {
+		return nil, &herr.PreconditionFailedError
+	}
+	return describe("update", in, before, in, by), nil
 }
 
 // DeleteEntity delete an entity in the datastore,
 // using the ID property of the given model.
-func DeleteEntity(in Identifiable) *herr.Error {
+func DeleteEntity(in Identifiable, by Actor) *herr.Error {
 	var count int
 	if err := Conn.Model(in).Where("id = ?", in.GetID()).Count(&count).Error; err != nil {
 		return &herr.Error{
@@ -151,6 +226,7 @@ func DeleteEntity(in Identifiable) *herr.Error {
 			Detail: "The requested resource was not found in the datastore.",
 		}
 	}
+	before := snapshot(Conn, in)
 	if err := Conn.Delete(in).Error; err != nil {
 		return &herr.Error{
 			ID:     "database-error",
@@ -159,5 +235,15 @@ func DeleteEntity(in Identifiable) *herr.Error {
 			Detail: err.Error(),
 		}
 	}
+	notify("delete", in, before, nil, by)
 	return nil
-}
\ No newline at end of file
+}
+
+// isUniqueViolation reports whether err was caused by a unique constraint,
+// as reported by the SQLite, MySQL and PostgreSQL drivers
+func isUniqueViolation(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") ||
+		strings.Contains(message, "Error 1062") ||
+		strings.Contains(message, "violates unique constraint")
+}
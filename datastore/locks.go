@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/torrent-viewer/backend/herr"
+)
+
+// Lock is a named lock shared by every instance using the datastore.
+// A lock is held by its Owner until it expires.
+type Lock struct {
+	Name      string `gorm:"primary_key"`
+	Owner     string
+	ExpiresAt time.Time
+}
+
+func (Lock) TableName() string {
+	return "locks"
+}
+
+// AcquireLock takes the lock of the given name on behalf of owner until the
+// given time. It returns false if the lock is currently held by another owner.
+// Acquiring a lock already held by owner extends it.
+func AcquireLock(name string, owner string, until time.Time) (bool, *herr.Error) {
+	d := Conn.Model(&Lock{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", name, owner, time.Now()).
+		UpdateColumns(map[string]interface{}{"owner": owner, "expires_at": until})
+	if err := d.Error; err != nil {
+		return false, &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	if d.RowsAffected == 1 {
+		return true, nil
+	}
+	var count int
+	if err := Conn.Model(&Lock{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	if count > 0 {
+		return false, nil
+	}
+	// Another instance may create the lock first, in which case it holds it
+	lock := Lock{Name: name, Owner: owner, ExpiresAt: until}
+	return Conn.Create(&lock).Error == nil, nil
+}
+
+// ReleaseLock releases the lock of the given name if it is held by owner
+func ReleaseLock(name string, owner string) *herr.Error {
+	if err := Conn.Where("name = ? AND owner = ?", name, owner).Delete(&Lock{}).Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package datastore
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Actor identifies who is responsible for a mutation of the datastore
+type Actor struct {
+	Principal string
+	RequestID string
+}
+
+// Mutation describes a change applied to an entity of the datastore.
+// Before and After are snapshots of the entity attributes,
+// Before is nil for a creation and After is nil for a deletion.
+type Mutation struct {
+	Action string
+	Type   string
+	ID     int
+	Before map[string]interface{}
+	After  map[string]interface{}
+	Actor  Actor
+	Time   time.Time
+}
+
+// Hook is a function called after each mutation of the datastore
+type Hook func(m Mutation)
+
+var hooks []Hook
+
+// OnMutation registers a Hook called after each successful mutation.
+// Hooks must be registered before the datastore is used.
+func OnMutation(hook Hook) {
+	hooks = append(hooks, hook)
+}
+
+func notify(action string, in interface{}, before interface{}, after interface{}, by Actor) {
+	publish(describe(action, in, before, after, by))
+}
+
+// describe returns the mutation of an entity, or nil if no hook needs it
+func describe(action string, in interface{}, before interface{}, after interface{}, by Actor) *Mutation {
+	if len(hooks) == 0 {
+		return nil
+	}
+	m := &Mutation{
+		Action: action,
+		Type:   ResourceType(in),
+		Before: Attributes(before),
+		After:  Attributes(after),
+		Actor:  by,
+		Time:   time.Now(),
+	}
+	if i, ok := in.(Identifiable); ok {
+		m.ID = i.GetID()
+	}
+	return m
+}
+
+// publish calls the hooks with the given mutation, if any
+func publish(m *Mutation) {
+	if m == nil {
+		return
+	}
+	for _, hook := range hooks {
+		hook(*m)
+	}
+}
+
+// Publish calls the hooks with a mutation that was not applied through the
+// datastore functions, such as a change of the state of an entity stored
+// without creating a new version of it.
+func Publish(m Mutation) {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	publish(&m)
+}
+
+// snapshot loads a copy of the stored version of the given entity through
+// db, or returns nil if no hook needs it.
+func snapshot(db *gorm.DB, in Identifiable) interface{} {
+	if len(hooks) == 0 {
+		return nil
+	}
+	out := reflect.New(reflect.Indirect(reflect.ValueOf(in)).Type()).Interface()
+	if err := db.Unscoped().First(out, in.GetID()).Error; err != nil {
+		return nil
+	}
+	return out
+}
+
+// ResourceType returns the JSON API type of the given entity
+func ResourceType(in interface{}) string {
+	t := reflect.TypeOf(in)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		args := strings.Split(t.Field(i).Tag.Get("jsonapi"), ",")
+		if len(args) >= 2 && args[0] == "primary" {
+			return args[1]
+		}
+	}
+	return t.Name()
+}
+
+// Attributes returns the JSON API attributes of the given entity,
+// or nil if in is nil.
+// Attributes tagged `secret:"true"` are left out, so that mutations never
+// disclose them.
+func Attributes(in interface{}) map[string]interface{} {
+	if in == nil {
+		return nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(in))
+	attributes := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("secret") == "true" {
+			continue
+		}
+		args := strings.Split(v.Type().Field(i).Tag.Get("jsonapi"), ",")
+		if len(args) >= 2 && args[0] == "attr" {
+			attributes[args[1]] = v.Field(i).Interface()
+		}
+	}
+	return attributes
+}
+
+// AttributeColumns maps the JSON API attributes of the given model
+// to their database columns.
+func AttributeColumns(model interface{}) map[string]string {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	columns := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		args := strings.Split(t.Field(i).Tag.Get("jsonapi"), ",")
+		if len(args) >= 2 && args[0] == "attr" {
+			columns[args[1]] = gorm.ToDBName(t.Field(i).Name)
+		}
+	}
+	return columns
+}
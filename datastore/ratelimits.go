@@ -0,0 +1,86 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/torrent-viewer/backend/router"
+)
+
+// RateLimit is a token bucket of a rate limiter, shared by every instance
+// using the datastore.
+// Stamp is the time of the last update of the bucket, in nanoseconds.
+type RateLimit struct {
+	Bucket string `gorm:"primary_key"`
+	Tokens float64
+	Stamp  int64
+}
+
+func (RateLimit) TableName() string {
+	return "rate_limits"
+}
+
+// RateLimits is a router.RateLimitStore keeping the buckets in the datastore
+type RateLimits struct{}
+
+// refilled is the SQL expression of the tokens of a bucket refilled
+// according to a limit, whose arguments are given by refilledArgs
+const refilled = "CASE WHEN stamp >= ? THEN tokens" +
+	" WHEN tokens + (? - stamp) * ? > ? THEN ?" +
+	" ELSE tokens + (? - stamp) * ? END"
+
+func refilledArgs(limit router.Limit, now time.Time) []interface{} {
+	stamp := now.UnixNano()
+	rate := limit.Rate / float64(time.Second)
+	return []interface{}{stamp, stamp, rate, limit.Burst, limit.Burst, stamp, rate}
+}
+
+// Take takes a token from the bucket of the given key.
+// The bucket is refilled and a token is taken by a single conditional
+// update, so that concurrent requests never take more tokens than there are.
+func (RateLimits) Take(key string, limit router.Limit, now time.Time) (bool, float64, error) {
+	args := refilledArgs(limit, now)
+	query := "UPDATE rate_limits SET tokens = " + refilled + " - 1," +
+		" stamp = CASE WHEN stamp > ? THEN stamp ELSE ? END" +
+		" WHERE bucket = ? AND " + refilled + " >= 1"
+	values := append(append(append([]interface{}{}, args...), now.UnixNano(), now.UnixNano(), key), args...)
+	for attempt := 0; attempt < 2; attempt++ {
+		d := Conn.Exec(query, values...)
+		if d.Error != nil {
+			return false, 0, d.Error
+		}
+		var buckets []RateLimit
+		if err := Conn.Where("bucket = ?", key).Limit(1).Find(&buckets).Error; err != nil {
+			return false, 0, err
+		}
+		if len(buckets) > 0 {
+			b := buckets[0]
+			return d.RowsAffected == 1, router.Refill(b.Tokens, limit, now.Sub(time.Unix(0, b.Stamp))), nil
+		}
+		// Another instance may create the bucket first, in which case
+		// a token is taken from it instead
+		b := RateLimit{Bucket: key, Tokens: float64(limit.Burst) - 1, Stamp: now.UnixNano()}
+		if err := Conn.Create(&b).Error; err == nil {
+			return true, b.Tokens, nil
+		}
+	}
+	return false, 0, fmt.Errorf("rate limit bucket %q could not be created", key)
+}
+
+// Tokens returns the tokens of the bucket of the given key
+func (RateLimits) Tokens(key string, limit router.Limit, now time.Time) (float64, error) {
+	var buckets []RateLimit
+	if err := Conn.Where("bucket = ?", key).Limit(1).Find(&buckets).Error; err != nil {
+		return 0, err
+	}
+	if len(buckets) == 0 {
+		return float64(limit.Burst), nil
+	}
+	return router.Refill(buckets[0].Tokens, limit, now.Sub(time.Unix(0, buckets[0].Stamp))), nil
+}
+
+// PruneRateLimits deletes the buckets that were not used since the given
+// time, which are full again for any reasonable limit
+func PruneRateLimits(before time.Time) error {
+	return Conn.Where("stamp < ?", before.UnixNano()).Delete(&RateLimit{}).Error
+}
@@ -0,0 +1,68 @@
+package datastore
+
+import (
+	"flag"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/router"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-datastore-test.db")
+	// SQLite refuses concurrent writes instead of waiting for them
+	Conn.DB().SetMaxOpenConns(1)
+	Conn.AutoMigrate(&RateLimit{})
+	ret := m.Run()
+	Conn.DropTable(&RateLimit{})
+	os.Exit(ret)
+}
+
+func TestRateLimitsTake(t *testing.T) {
+	limit := router.Limit{Rate: 10, Burst: 2}
+	now := time.Now()
+	for i, expected := range []bool{true, true, false} {
+		if allowed, _, err := (RateLimits{}).Take("ip:192.0.2.1", limit, now); err != nil || allowed != expected {
+			t.Errorf("Expected request %d to be allowed: %t, got %t (%v)", i, expected, allowed, err)
+		}
+	}
+	allowed, remaining, err := RateLimits{}.Take("ip:192.0.2.1", limit, now.Add(150*time.Millisecond))
+	if err != nil || !allowed {
+		t.Errorf("Expected the bucket to be refilled, got %t (%v)", allowed, err)
+	}
+	if remaining < 0.49 || remaining > 0.51 {
+		t.Errorf("Expected 0.5 tokens left, got %f", remaining)
+	}
+}
+
+func TestRateLimitsTakeConcurrently(t *testing.T) {
+	limit := router.Limit{Rate: 0.001, Burst: 5}
+	now := time.Now()
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	taken := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed, _, err := RateLimits{}.Take("ip:192.0.2.2", limit, now)
+			if err != nil {
+				t.Error(err)
+			}
+			if allowed {
+				mutex.Lock()
+				taken++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != limit.Burst {
+		t.Errorf("Expected %d tokens to be taken, got %d", limit.Burst, taken)
+	}
+}
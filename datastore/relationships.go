@@ -0,0 +1,314 @@
+package datastore
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+	"github.com/torrent-viewer/backend/herr"
+)
+
+// Relationship describes how an entity is linked to entities of another type.
+// The foreign key is a field of the entity itself for to-one relationships,
+// and a field of the related entities for to-many relationships.
+// A foreign key declared as a pointer can be unset, removing the link.
+// The foreign key of an Optional relationship is set to 0 to remove the link.
+type Relationship struct {
+	Name       string
+	Type       string
+	ForeignKey string
+	ToMany     bool
+	Optional   bool
+}
+
+// Related represent an entity linked to other entities
+type Related interface {
+	Relationships() []Relationship
+}
+
+var models = make(map[string]reflect.Type)
+
+// Register makes the given models available as targets of relationships,
+// under their JSON API resource type.
+func Register(in ...interface{}) {
+	for _, model := range in {
+		models[ResourceType(model)] = reflect.Indirect(reflect.ValueOf(model)).Type()
+	}
+}
+
+// NewEntity returns a pointer to a new entity of the given resource type,
+// or nil if no model was registered for it.
+func NewEntity(resourceType string) Identifiable {
+	t, ok := models[resourceType]
+	if !ok {
+		return nil
+	}
+	entity, _ := reflect.New(t).Interface().(Identifiable)
+	return entity
+}
+
+// FindRelationship returns the relationship of the given entity named name
+func FindRelationship(in interface{}, name string) (Relationship, bool) {
+	if related, ok := in.(Related); ok {
+		for _, rel := range related.Relationships() {
+			if rel.Name == name {
+				return rel, true
+			}
+		}
+	}
+	return Relationship{}, false
+}
+
+// RelationshipNames returns the names of the relationships of the given entity
+func RelationshipNames(in interface{}) []string {
+	names := []string{}
+	if related, ok := in.(Related); ok {
+		for _, rel := range related.Relationships() {
+			names = append(names, rel.Name)
+		}
+	}
+	return names
+}
+
+func foreignKey(in interface{}, rel Relationship) reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(in)).FieldByName(rel.ForeignKey)
+}
+
+// FetchLinkage returns the IDs of the entities linked to in through rel
+func FetchLinkage(in Identifiable, rel Relationship) ([]int, *herr.Error) {
+	return fetchLinkage(Conn, in, rel)
+}
+
+func fetchLinkage(db *gorm.DB, in Identifiable, rel Relationship) ([]int, *herr.Error) {
+	ids := []int{}
+	if rel.ToMany == false {
+		fk := reflect.Indirect(foreignKey(in, rel))
+		if fk.IsValid() && fk.Int() != 0 {
+			ids = append(ids, int(fk.Int()))
+		}
+		return ids, nil
+	}
+	related := NewEntity(rel.Type)
+	if related == nil {
+		return nil, unknownType(rel.Type)
+	}
+	column := gorm.ToDBName(rel.ForeignKey)
+	if err := db.Model(related).Where(fmt.Sprintf("%s = ?", column), in.GetID()).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	return ids, nil
+}
+
+// SetToOne links in to the entity of ID id through the to-one relationship rel.
+// An ID of 0 removes the link.
+func SetToOne(in Identifiable, rel Relationship, id int, by Actor) *herr.Error {
+	if err := checkRelated(rel, id); err != nil {
+		return err
+	}
+	if err := setForeignKey(in, rel, id); err != nil {
+		return err
+	}
+	return UpdateEntity(in, []string{rel.ForeignKey}, by)
+}
+
+// AddToMany links the entities of the given IDs to in through the to-many relationship rel
+func AddToMany(in Identifiable, rel Relationship, ids []int, by Actor) *herr.Error {
+	return transaction(func(tx *gorm.DB) ([]*Mutation, *herr.Error) {
+		return linkToMany(tx, in.GetID(), rel, ids, by)
+	})
+}
+
+// RemoveToMany unlinks the entities of the given IDs from in through the
+// to-many relationship rel. Entities that are not linked to in are ignored.
+func RemoveToMany(in Identifiable, rel Relationship, ids []int, by Actor) *herr.Error {
+	return transaction(func(tx *gorm.DB) ([]*Mutation, *herr.Error) {
+		linked, err := fetchLinkage(tx, in, rel)
+		if err != nil {
+			return nil, err
+		}
+		unlinked := []int{}
+		for _, id := range ids {
+			if containsInt(linked, id) {
+				unlinked = append(unlinked, id)
+			}
+		}
+		return linkToMany(tx, 0, rel, unlinked, by)
+	})
+}
+
+// ReplaceToMany links exactly the entities of the given IDs to in
+// through the to-many relationship rel.
+func ReplaceToMany(in Identifiable, rel Relationship, ids []int, by Actor) *herr.Error {
+	return transaction(func(tx *gorm.DB) ([]*Mutation, *herr.Error) {
+		linked, err := fetchLinkage(tx, in, rel)
+		if err != nil {
+			return nil, err
+		}
+		removed := []int{}
+		for _, l := range linked {
+			if containsInt(ids, l) == false {
+				removed = append(removed, l)
+			}
+		}
+		unlinks, err := linkToMany(tx, 0, rel, removed, by)
+		if err != nil {
+			return nil, err
+		}
+		links, err := linkToMany(tx, in.GetID(), rel, ids, by)
+		if err != nil {
+			return nil, err
+		}
+		return append(unlinks, links...), nil
+	})
+}
+
+// linkToMany sets the foreign key of rel to id on the entities of the given
+// IDs through the transaction tx, and returns their mutations
+func linkToMany(tx *gorm.DB, id int, rel Relationship, ids []int, by Actor) ([]*Mutation, *herr.Error) {
+	mutations := []*Mutation{}
+	for _, relatedID := range ids {
+		related := NewEntity(rel.Type)
+		if related == nil {
+			return nil, unknownType(rel.Type)
+		}
+		if err := fetchEntity(tx, related, relatedID); err != nil {
+			return nil, err
+		}
+		if err := setForeignKey(related, rel, id); err != nil {
+			return nil, err
+		}
+		m, err := updateEntity(tx, related, []string{rel.ForeignKey}, by)
+		if err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, m)
+	}
+	return mutations, nil
+}
+
+// transaction runs f within a database transaction, which is rolled back if
+// f fails. The mutations returned by f are notified once it is committed.
+func transaction(f func(tx *gorm.DB) ([]*Mutation, *herr.Error)) *herr.Error {
+	tx := Conn.Begin()
+	if err := tx.Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	mutations, err := f(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	for _, m := range mutations {
+		publish(m)
+	}
+	return nil
+}
+
+// CheckLinks ensures that the entities referenced by the to-one
+// relationships of in exist.
+func CheckLinks(in Related) *herr.Error {
+	for _, rel := range in.Relationships() {
+		if rel.ToMany {
+			continue
+		}
+		fk := reflect.Indirect(foreignKey(in, rel))
+		if fk.IsValid() == false {
+			continue
+		}
+		if err := checkRelated(rel, int(fk.Int())); err != nil {
+			err.Source.Pointer = fmt.Sprintf("/data/attributes/%s", gorm.ToDBName(rel.ForeignKey))
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRelated ensures that the entity of ID id exists,
+// so that it can be linked through rel.
+func checkRelated(rel Relationship, id int) *herr.Error {
+	if id == 0 {
+		return nil
+	}
+	related := NewEntity(rel.Type)
+	if related == nil {
+		return unknownType(rel.Type)
+	}
+	var count int
+	if err := CountEntities(related, &count, Where("id = ?", id)); err != nil {
+		return err
+	}
+	if count == 0 {
+		return &herr.Error{
+			ID:     "not-found",
+			Status: "404",
+			Title:  "Not Found",
+			Detail: fmt.Sprintf("The related %s %d was not found in the datastore.", rel.Type, id),
+			Source: herr.ErrorSource{
+				Pointer: "/data",
+			},
+		}
+	}
+	return nil
+}
+
+// setForeignKey sets the foreign key of rel held by entity to id.
+// An ID of 0 unsets the foreign key if it can be unset.
+func setForeignKey(entity interface{}, rel Relationship, id int) *herr.Error {
+	fk := foreignKey(entity, rel)
+	if fk.Kind() == reflect.Ptr {
+		if id == 0 {
+			fk.Set(reflect.Zero(fk.Type()))
+		} else {
+			value := reflect.New(fk.Type().Elem())
+			value.Elem().SetInt(int64(id))
+			fk.Set(value)
+		}
+		return nil
+	}
+	if id == 0 && rel.Optional == false {
+		return &herr.Error{
+			ID:     "forbidden",
+			Status: "403",
+			Title:  "Forbidden",
+			Detail: fmt.Sprintf("Links of the %s relationship cannot be removed.", rel.Name),
+		}
+	}
+	fk.SetInt(int64(id))
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownType(resourceType string) *herr.Error {
+	return &herr.Error{
+		ID:     "unknown-type",
+		Status: "500",
+		Title:  "Unknown resource type",
+		Detail: fmt.Sprintf("No model was registered for the %s type", resourceType),
+	}
+}
@@ -0,0 +1,126 @@
+package datastore
+
+import (
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/torrent-viewer/backend/herr"
+)
+
+// WithoutTrashed is a Scope excluding soft-deleted entities, which is the default
+func WithoutTrashed(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// WithTrashed is a Scope including soft-deleted entities
+func WithTrashed(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// OnlyTrashed is a Scope restricted to soft-deleted entities
+func OnlyTrashed(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// RestoreEntity restore a soft-deleted entity in the datastore,
+// using the ID property of the given model.
+func RestoreEntity(in Identifiable, by Actor) *herr.Error {
+	d := Conn.Unscoped().Model(in).Where("id = ? AND deleted_at IS NOT NULL", in.GetID()).UpdateColumn("deleted_at", gorm.Expr("NULL"))
+	if err := d.Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	if d.RowsAffected == 0 {
+		return &herr.Error{
+			ID:     "not-found",
+			Status: "404",
+			Title:  "Not Found",
+			Detail: "The requested resource was not found in the trash.",
+		}
+	}
+	notify("restore", in, nil, snapshot(Conn, in), by)
+	return nil
+}
+
+// PurgeEntity permanently delete an entity from the datastore,
+// whether it was soft-deleted or not.
+func PurgeEntity(in Identifiable, by Actor) *herr.Error {
+	before := snapshot(Conn, in)
+	d := Conn.Unscoped().Where("id = ?", in.GetID()).Delete(in)
+	if err := d.Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	if d.RowsAffected == 0 {
+		return &herr.Error{
+			ID:     "not-found",
+			Status: "404",
+			Title:  "Not Found",
+			Detail: "The requested resource was not found in the datastore.",
+		}
+	}
+	notify("purge", in, before, nil, by)
+	return nil
+}
+
+// PurgeTrashed permanently delete the entities of the model's table
+// that were soft-deleted before the given time, one at a time so that the
+// mutation hooks are called for each of them.
+// It returns the number of purged entities.
+func PurgeTrashed(model interface{}, before time.Time, by Actor) (int64, *herr.Error) {
+	entities := reflect.New(reflect.SliceOf(reflect.Indirect(reflect.ValueOf(model)).Type()))
+	if err := Conn.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Find(entities.Interface()).Error; err != nil {
+		return 0, &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	var count int64
+	for i := 0; i < entities.Elem().Len(); i++ {
+		in := entities.Elem().Index(i).Addr().Interface().(Identifiable)
+		// The entity is left alone if it was restored in the meantime
+		d := Conn.Unscoped().Where("id = ? AND deleted_at IS NOT NULL AND deleted_at < ?", in.GetID(), before).Delete(in)
+		if err := d.Error; err != nil {
+			return count, &herr.Error{
+				ID:     "database-error",
+				Status: "500",
+				Title:  "Database Error",
+				Detail: err.Error(),
+			}
+		}
+		if d.RowsAffected > 0 {
+			count++
+			notify("purge", in, in, nil, by)
+		}
+	}
+	return count, nil
+}
+
+// CollectTrash purges, every interval, the entities of the given models that
+// were soft-deleted for longer than retention. It never returns.
+func CollectTrash(interval time.Duration, retention time.Duration, models ...interface{}) {
+	by := Actor{Principal: "scheduler"}
+	for {
+		for _, model := range models {
+			count, err := PurgeTrashed(model, time.Now().Add(-retention), by)
+			if err != nil {
+				log.Printf("Could not purge trashed %T: %s\n", model, err.Detail)
+			} else if count > 0 {
+				log.Printf("Purged %d trashed %T\n", count, model)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Torrent is a torrent to add to a download client, given either as a magnet
+// link or as the content of a .torrent file.
+// Category and Tags are ignored by clients that do not support them.
+type Torrent struct {
+	MagnetURI string
+	Metainfo  []byte
+	Directory string
+	Category  string
+	Tags      []string
+}
+
+// States of the torrents of a download client
+const (
+	StateQueued      = "queued"
+	StateChecking    = "checking"
+	StateDownloading = "downloading"
+	StateSeeding     = "seeding"
+	StatePaused      = "paused"
+	StateError       = "error"
+)
+
+// Status is the state of a torrent in a download client.
+// Progress goes from 0 to 1.
+type Status struct {
+	Hash     string
+	Name     string
+	State    string
+	Progress float64
+}
+
+// Client is a download client torrents can be sent to
+type Client interface {
+	// Check ensures that the download client can be reached with the
+	// configured credentials
+	Check() error
+	// Add sends a torrent to the download client, and returns its info hash
+	Add(t Torrent) (string, error)
+	// Statuses returns the status of the torrents of the given info hashes,
+	// or of every torrent if no hash is given
+	Statuses(hashes ...string) ([]Status, error)
+}
+
+// Config describes how to reach a download client.
+// Directory and Category are used for torrents that do not specify one.
+type Config struct {
+	Kind      string
+	URL       string
+	Username  string
+	Password  string
+	Directory string
+	Category  string
+}
+
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// New returns the Client described by config
+func New(config Config) (Client, error) {
+	switch config.Kind {
+	case "transmission":
+		return NewTransmission(config), nil
+	case "qbittorrent":
+		return NewQBittorrent(config)
+	}
+	return nil, fmt.Errorf("unsupported download client %q", config.Kind)
+}
@@ -0,0 +1,340 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// QBittorrent is a Client for the qBittorrent v2 Web API
+type QBittorrent struct {
+	config   Config
+	client   *http.Client
+	mutex    sync.Mutex
+	loggedIn bool
+}
+
+type qbittorrentTorrent struct {
+	Hash     string  `json:"hash"`
+	Name     string  `json:"name"`
+	State    string  `json:"state"`
+	Progress float64 `json:"progress"`
+}
+
+// qbittorrentStates maps the states of qBittorrent to our states
+var qbittorrentStates = map[string]string{
+	"error":              StateError,
+	"missingFiles":       StateError,
+	"uploading":          StateSeeding,
+	"stalledUP":          StateSeeding,
+	"forcedUP":           StateSeeding,
+	"pausedUP":           StatePaused,
+	"stoppedUP":          StatePaused,
+	"queuedUP":           StateQueued,
+	"checkingUP":         StateChecking,
+	"downloading":        StateDownloading,
+	"stalledDL":          StateDownloading,
+	"forcedDL":           StateDownloading,
+	"metaDL":             StateDownloading,
+	"forcedMetaDL":       StateDownloading,
+	"pausedDL":           StatePaused,
+	"stoppedDL":          StatePaused,
+	"queuedDL":           StateQueued,
+	"checkingDL":         StateChecking,
+	"checkingResumeData": StateChecking,
+	"allocating":         StateChecking,
+	"moving":             StateChecking,
+}
+
+// NewQBittorrent creates a qBittorrent client.
+// config.URL is the address of the web UI, such as http://localhost:8080.
+func NewQBittorrent(config Config) (*QBittorrent, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return &QBittorrent{
+		config: config,
+		client: &http.Client{
+			Timeout: httpClient.Timeout,
+			Jar:     jar,
+		},
+	}, nil
+}
+
+// login opens a session, whose cookie is kept by the cookie jar
+func (q *QBittorrent) login() error {
+	form := url.Values{}
+	form.Set("username", q.config.Username)
+	form.Set("password", q.config.Password)
+	request, err := http.NewRequest("POST", q.config.URL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// qBittorrent rejects requests whose Referer or Origin do not match its host
+	request.Header.Set("Referer", q.config.URL)
+	response, err := q.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("qbittorrent login: invalid credentials (HTTP %d)", response.StatusCode)
+	}
+	return nil
+}
+
+// call sends a request to the given endpoint of the API, logging in first if
+// there is no session or if it expired, and returns the body of the response
+func (q *QBittorrent) call(method string, endpoint string, contentType string, body []byte) ([]byte, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if !q.loggedIn {
+			if err := q.login(); err != nil {
+				return nil, err
+			}
+			q.loggedIn = true
+		}
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		request, err := http.NewRequest(method, q.config.URL+"/api/v2/"+endpoint, reader)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		request.Header.Set("Referer", q.config.URL)
+		response, err := q.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// qBittorrent answers 403 when the session is missing or expired
+		if response.StatusCode == http.StatusForbidden {
+			q.loggedIn = false
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("qbittorrent %s: unexpected HTTP %d", endpoint, response.StatusCode)
+		}
+		return content, nil
+	}
+	return nil, fmt.Errorf("qbittorrent %s: access denied", endpoint)
+}
+
+// Check ensures that the web API can be reached
+func (q *QBittorrent) Check() error {
+	_, err := q.call("GET", "app/version", "", nil)
+	return err
+}
+
+// Add adds a torrent to qBittorrent and returns its info hash.
+// The Web API does not return the hash, so it is read from the magnet link
+// or computed from the metainfo.
+func (q *QBittorrent) Add(torrent Torrent) (string, error) {
+	var hash string
+	var err error
+	if len(torrent.Metainfo) > 0 {
+		hash, err = metainfoHash(torrent.Metainfo)
+	} else {
+		hash, err = magnetHash(torrent.MagnetURI)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	if len(torrent.Metainfo) > 0 {
+		file, err := form.CreateFormFile("torrents", hash+".torrent")
+		if err != nil {
+			return "", err
+		}
+		file.Write(torrent.Metainfo)
+	} else {
+		form.WriteField("urls", torrent.MagnetURI)
+	}
+	directory := torrent.Directory
+	if directory == "" {
+		directory = q.config.Directory
+	}
+	if directory != "" {
+		form.WriteField("savepath", directory)
+	}
+	category := torrent.Category
+	if category == "" {
+		category = q.config.Category
+	}
+	if category != "" {
+		form.WriteField("category", category)
+	}
+	if len(torrent.Tags) > 0 {
+		form.WriteField("tags", strings.Join(torrent.Tags, ","))
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	response, err := q.call("POST", "torrents/add", form.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(response)) != "Ok." {
+		return "", fmt.Errorf("qbittorrent torrents/add: torrent rejected")
+	}
+	return hash, nil
+}
+
+// Statuses returns the status of the given torrents, or of every torrent
+// if no hash is given
+func (q *QBittorrent) Statuses(hashes ...string) ([]Status, error) {
+	endpoint := "torrents/info"
+	if len(hashes) > 0 {
+		endpoint += "?hashes=" + url.QueryEscape(strings.Join(hashes, "|"))
+	}
+	response, err := q.call("GET", endpoint, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var torrents []qbittorrentTorrent
+	if err := json.Unmarshal(response, &torrents); err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(torrents))
+	for i, torrent := range torrents {
+		state, ok := qbittorrentStates[torrent.State]
+		if !ok {
+			state = StateQueued
+		}
+		statuses[i] = Status{
+			Hash:     strings.ToLower(torrent.Hash),
+			Name:     torrent.Name,
+			State:    state,
+			Progress: torrent.Progress,
+		}
+	}
+	return statuses, nil
+}
+
+// magnetHash returns the info hash of a magnet link, in lowercase hexadecimal
+func magnetHash(magnet string) (string, error) {
+	u, err := url.Parse(magnet)
+	if err != nil || u.Scheme != "magnet" {
+		return "", fmt.Errorf("invalid magnet link %q", magnet)
+	}
+	for _, topic := range u.Query()["xt"] {
+		if !strings.HasPrefix(topic, "urn:btih:") {
+			continue
+		}
+		hash := strings.TrimPrefix(topic, "urn:btih:")
+		switch len(hash) {
+		case 40:
+			if _, err := hex.DecodeString(hash); err == nil {
+				return strings.ToLower(hash), nil
+			}
+		case 32:
+			if decoded, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash)); err == nil {
+				return hex.EncodeToString(decoded), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no info hash in magnet link %q", magnet)
+}
+
+// metainfoHash returns the info hash of a .torrent file, that is the SHA-1
+// of its bencoded info dictionary
+func metainfoHash(metainfo []byte) (string, error) {
+	if len(metainfo) == 0 || metainfo[0] != 'd' {
+		return "", fmt.Errorf("invalid torrent file")
+	}
+	i := 1
+	for i < len(metainfo) && metainfo[i] != 'e' {
+		key, next, err := bencodedString(metainfo, i)
+		if err != nil {
+			return "", err
+		}
+		end, err := skipBencoded(metainfo, next)
+		if err != nil {
+			return "", err
+		}
+		if key == "info" {
+			sum := sha1.Sum(metainfo[next:end])
+			return hex.EncodeToString(sum[:]), nil
+		}
+		i = end
+	}
+	return "", fmt.Errorf("invalid torrent file: no info dictionary")
+}
+
+// bencodedString decodes the bencoded string starting at offset i, and
+// returns it with the offset following it
+func bencodedString(data []byte, i int) (string, int, error) {
+	length := 0
+	for ; i < len(data) && data[i] >= '0' && data[i] <= '9'; i++ {
+		length = length*10 + int(data[i]-'0')
+		if length > len(data) {
+			return "", 0, fmt.Errorf("invalid torrent file")
+		}
+	}
+	if i >= len(data) || data[i] != ':' || i+1+length > len(data) {
+		return "", 0, fmt.Errorf("invalid torrent file")
+	}
+	return string(data[i+1 : i+1+length]), i + 1 + length, nil
+}
+
+// skipBencoded returns the offset following the bencoded value starting at i
+func skipBencoded(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, fmt.Errorf("invalid torrent file")
+	}
+	switch {
+	case data[i] == 'i':
+		end := bytes.IndexByte(data[i:], 'e')
+		if end < 0 {
+			return 0, fmt.Errorf("invalid torrent file")
+		}
+		return i + end + 1, nil
+	case data[i] == 'l' || data[i] == 'd':
+		i++
+		for i < len(data) && data[i] != 'e' {
+			next, err := skipBencoded(data, i)
+			if err != nil {
+				return 0, err
+			}
+			i = next
+		}
+		if i >= len(data) {
+			return 0, fmt.Errorf("invalid torrent file")
+		}
+		return i + 1, nil
+	case data[i] >= '0' && data[i] <= '9':
+		_, next, err := bencodedString(data, i)
+		return next, err
+	}
+	return 0, fmt.Errorf("invalid torrent file")
+}
@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// fakeQBittorrent serves the qBittorrent Web API, requiring a session opened
+// with the given credentials, and records the forms sent to torrents/add
+func fakeQBittorrent(t *testing.T, username string, password string) (*httptest.Server, *[]url.Values) {
+	added := []url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/auth/login" {
+			r.ParseForm()
+			if r.PostForm.Get("username") != username || r.PostForm.Get("password") != password {
+				w.Write([]byte("Fails."))
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+			w.Write([]byte("Ok."))
+			return
+		}
+		if cookie, err := r.Cookie("SID"); err != nil || cookie.Value != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/app/version":
+			w.Write([]byte("v4.1.3"))
+		case "/api/v2/torrents/add":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Error(err)
+				return
+			}
+			form := url.Values(r.MultipartForm.Value)
+			if files := r.MultipartForm.File["torrents"]; len(files) > 0 {
+				form.Set("file", files[0].Filename)
+			}
+			added = append(added, form)
+			w.Write([]byte("Ok."))
+		case "/api/v2/torrents/info":
+			if r.URL.Query().Get("hashes") != "c0ffee|d00d" {
+				t.Errorf("Unexpected hashes %q", r.URL.Query().Get("hashes"))
+			}
+			w.Write([]byte(`[
+				{"hash": "C0FFEE", "name": "Pilot", "state": "stalledDL", "progress": 0.25},
+				{"hash": "D00D", "name": "Traces to Nowhere", "state": "uploading", "progress": 1}
+			]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &added
+}
+
+func TestQBittorrentAdd(t *testing.T) {
+	server, added := fakeQBittorrent(t, "admin", "secret")
+	defer server.Close()
+	client, err := New(Config{Kind: "qbittorrent", URL: server.URL + "/", Username: "admin", Password: "secret", Directory: "/downloads", Category: "tv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Check(); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := client.Add(Torrent{MagnetURI: "magnet:?xt=urn:btih:C0FFEEC0FFEEC0FFEEC0FFEEC0FFEEC0FFEEC0FF&dn=Pilot", Tags: []string{"twin-peaks", "s01"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "c0ffeec0ffeec0ffeec0ffeec0ffeec0ffeec0ff" {
+		t.Errorf("Expected the info hash of the magnet link, got %q", hash)
+	}
+	info := "d6:lengthi42e4:name5:Pilot12:piece lengthi16384e6:pieces0:e"
+	metainfo := []byte("d8:announce9:localhost4:info" + info + "e")
+	hash, err = client.Add(Torrent{Metainfo: metainfo, Directory: "/elsewhere", Category: "films"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum([]byte(info))
+	if expected := hex.EncodeToString(sum[:]); hash != expected {
+		t.Errorf("Expected info hash %q, got %q", expected, hash)
+	}
+
+	if len(*added) != 2 {
+		t.Fatalf("Expected 2 torrents to be added, got %d", len(*added))
+	}
+	first, second := (*added)[0], (*added)[1]
+	if first.Get("savepath") != "/downloads" || first.Get("category") != "tv" || first.Get("tags") != "twin-peaks,s01" {
+		t.Errorf("Expected the default directory and category with the tags, got %v", first)
+	}
+	if second.Get("savepath") != "/elsewhere" || second.Get("category") != "films" || second.Get("file") == "" {
+		t.Errorf("Expected the torrent file with its directory and category, got %v", second)
+	}
+}
+
+func TestQBittorrentStatuses(t *testing.T) {
+	server, _ := fakeQBittorrent(t, "admin", "secret")
+	defer server.Close()
+	client, err := NewQBittorrent(Config{URL: server.URL, Username: "admin", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := client.Statuses("c0ffee", "d00d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Status{
+		{Hash: "c0ffee", Name: "Pilot", State: StateDownloading, Progress: 0.25},
+		{Hash: "d00d", Name: "Traces to Nowhere", State: StateSeeding, Progress: 1},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected %d statuses, got %v", len(expected), statuses)
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], statuses[i])
+		}
+	}
+}
+
+func TestQBittorrentErrors(t *testing.T) {
+	server, _ := fakeQBittorrent(t, "admin", "secret")
+	defer server.Close()
+	client, err := NewQBittorrent(Config{URL: server.URL, Username: "admin", Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Check(); err == nil {
+		t.Error("Expected invalid credentials to fail")
+	}
+	client, _ = NewQBittorrent(Config{URL: server.URL, Username: "admin", Password: "secret"})
+	if _, err := client.Add(Torrent{MagnetURI: "magnet:?dn=Pilot"}); err == nil {
+		t.Error("Expected a magnet link without info hash to be rejected")
+	}
+	if _, err := client.Add(Torrent{Metainfo: []byte("d8:announce9:localhoste")}); err == nil {
+		t.Error("Expected a torrent file without info dictionary to be rejected")
+	}
+	// The session is opened again once it expires
+	if err := client.Check(); err != nil {
+		t.Fatal(err)
+	}
+	client.client.Jar, _ = cookiejar.New(nil)
+	if err := client.Check(); err != nil {
+		t.Errorf("Expected the session to be renewed, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// sessionHeader is the header carrying the CSRF token of Transmission
+const sessionHeader = "X-Transmission-Session-Id"
+
+// Transmission is a Client for the Transmission RPC protocol
+type Transmission struct {
+	config    Config
+	mutex     sync.Mutex
+	sessionID string
+}
+
+type transmissionRequest struct {
+	Method    string      `json:"method"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+type transmissionResponse struct {
+	Result    string          `json:"result"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type transmissionTorrent struct {
+	HashString  string  `json:"hashString"`
+	Name        string  `json:"name"`
+	Status      int     `json:"status"`
+	PercentDone float64 `json:"percentDone"`
+	Error       int     `json:"error"`
+}
+
+// transmissionStates maps the status codes of Transmission to our states
+var transmissionStates = map[int]string{
+	0: StatePaused,
+	1: StateQueued,
+	2: StateChecking,
+	3: StateQueued,
+	4: StateDownloading,
+	5: StateQueued,
+	6: StateSeeding,
+}
+
+// NewTransmission creates a Transmission client.
+// config.URL is the RPC endpoint, usually ending with /transmission/rpc.
+func NewTransmission(config Config) *Transmission {
+	return &Transmission{
+		config: config,
+	}
+}
+
+// call sends an RPC request, renewing the session ID if it expired,
+// and decodes the arguments of the response into out
+func (t *Transmission) call(method string, arguments interface{}, out interface{}) error {
+	body, err := json.Marshal(transmissionRequest{Method: method, Arguments: arguments})
+	if err != nil {
+		return err
+	}
+	var response *http.Response
+	// Transmission answers 409 with a new session ID when it is missing or expired
+	for attempt := 0; attempt < 2; attempt++ {
+		request, err := http.NewRequest("POST", t.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		if t.config.Username != "" {
+			request.SetBasicAuth(t.config.Username, t.config.Password)
+		}
+		t.mutex.Lock()
+		request.Header.Set(sessionHeader, t.sessionID)
+		t.mutex.Unlock()
+		response, err = httpClient.Do(request)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode != http.StatusConflict {
+			break
+		}
+		response.Body.Close()
+		t.mutex.Lock()
+		t.sessionID = response.Header.Get(sessionHeader)
+		t.mutex.Unlock()
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("transmission %s: unexpected HTTP %d", method, response.StatusCode)
+	}
+	var decoded transmissionResponse
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return err
+	}
+	if decoded.Result != "success" {
+		return fmt.Errorf("transmission %s: %s", method, decoded.Result)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(decoded.Arguments, out)
+}
+
+// Check ensures that the RPC endpoint can be reached
+func (t *Transmission) Check() error {
+	return t.call("session-get", nil, nil)
+}
+
+// Add adds a torrent to Transmission and returns its info hash.
+// Adding a torrent already known to Transmission is not an error.
+func (t *Transmission) Add(torrent Torrent) (string, error) {
+	arguments := make(map[string]interface{})
+	if len(torrent.Metainfo) > 0 {
+		arguments["metainfo"] = base64.StdEncoding.EncodeToString(torrent.Metainfo)
+	} else {
+		arguments["filename"] = torrent.MagnetURI
+	}
+	directory := torrent.Directory
+	if directory == "" {
+		directory = t.config.Directory
+	}
+	if directory != "" {
+		arguments["download-dir"] = directory
+	}
+	var added struct {
+		Added     *transmissionTorrent `json:"torrent-added"`
+		Duplicate *transmissionTorrent `json:"torrent-duplicate"`
+	}
+	if err := t.call("torrent-add", arguments, &added); err != nil {
+		return "", err
+	}
+	if added.Added != nil {
+		return strings.ToLower(added.Added.HashString), nil
+	}
+	if added.Duplicate != nil {
+		return strings.ToLower(added.Duplicate.HashString), nil
+	}
+	return "", fmt.Errorf("transmission torrent-add: no torrent in response")
+}
+
+// Statuses returns the status of the given torrents, or of every torrent
+// if no hash is given
+func (t *Transmission) Statuses(hashes ...string) ([]Status, error) {
+	arguments := map[string]interface{}{
+		"fields": []string{"hashString", "name", "status", "percentDone", "error"},
+	}
+	if len(hashes) > 0 {
+		arguments["ids"] = hashes
+	}
+	var listed struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}
+	if err := t.call("torrent-get", arguments, &listed); err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(listed.Torrents))
+	for i, torrent := range listed.Torrents {
+		state := transmissionStates[torrent.Status]
+		if torrent.Error != 0 {
+			state = StateError
+		}
+		statuses[i] = Status{
+			Hash:     strings.ToLower(torrent.HashString),
+			Name:     torrent.Name,
+			State:    state,
+			Progress: torrent.PercentDone,
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeTransmission serves the Transmission RPC protocol, requiring the
+// session handshake and the given credentials
+func fakeTransmission(t *testing.T, username string, password string) (*httptest.Server, *[]transmissionRequest) {
+	received := []transmissionRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != username || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(sessionHeader) != "session" {
+			w.Header().Set(sessionHeader, "session")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		var request struct {
+			Method    string                 `json:"method"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+			return
+		}
+		received = append(received, transmissionRequest{Method: request.Method, Arguments: request.Arguments})
+		switch {
+		case request.Method == "session-get":
+			w.Write([]byte(`{"result": "success", "arguments": {"version": "2.94"}}`))
+		case request.Method == "torrent-add" && request.Arguments["filename"] == "magnet:?xt=urn:btih:duplicate":
+			w.Write([]byte(`{"result": "success", "arguments": {"torrent-duplicate": {"hashString": "D00D"}}}`))
+		case request.Method == "torrent-add":
+			w.Write([]byte(`{"result": "success", "arguments": {"torrent-added": {"hashString": "C0FFEE"}}}`))
+		case request.Method == "torrent-get":
+			w.Write([]byte(`{"result": "success", "arguments": {"torrents": [
+				{"hashString": "C0FFEE", "name": "Pilot", "status": 4, "percentDone": 0.25, "error": 0},
+				{"hashString": "D00D", "name": "Traces to Nowhere", "status": 0, "percentDone": 0.5, "error": 3}
+			]}}`))
+		default:
+			w.Write([]byte(`{"result": "method name not recognized"}`))
+		}
+	}))
+	return server, &received
+}
+
+func TestTransmissionAdd(t *testing.T) {
+	server, received := fakeTransmission(t, "admin", "secret")
+	defer server.Close()
+	client, err := New(Config{Kind: "transmission", URL: server.URL, Username: "admin", Password: "secret", Directory: "/downloads"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Check(); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := client.Add(Torrent{MagnetURI: "magnet:?xt=urn:btih:c0ffee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "c0ffee" {
+		t.Errorf("Expected info hash %q, got %q", "c0ffee", hash)
+	}
+	hash, err = client.Add(Torrent{MagnetURI: "magnet:?xt=urn:btih:duplicate", Directory: "/elsewhere"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "d00d" {
+		t.Errorf("Expected info hash %q, got %q", "d00d", hash)
+	}
+	if len(*received) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(*received))
+	}
+	arguments := (*received)[1].Arguments.(map[string]interface{})
+	if arguments["download-dir"] != "/downloads" {
+		t.Errorf("Expected the default directory, got %v", arguments["download-dir"])
+	}
+	arguments = (*received)[2].Arguments.(map[string]interface{})
+	if arguments["download-dir"] != "/elsewhere" {
+		t.Errorf("Expected the torrent directory, got %v", arguments["download-dir"])
+	}
+}
+
+func TestTransmissionStatuses(t *testing.T) {
+	server, received := fakeTransmission(t, "admin", "secret")
+	defer server.Close()
+	client := NewTransmission(Config{URL: server.URL, Username: "admin", Password: "secret"})
+	statuses, err := client.Statuses("c0ffee", "d00d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Status{
+		{Hash: "c0ffee", Name: "Pilot", State: StateDownloading, Progress: 0.25},
+		{Hash: "d00d", Name: "Traces to Nowhere", State: StateError, Progress: 0.5},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected %d statuses, got %v", len(expected), statuses)
+	}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], statuses[i])
+		}
+	}
+	arguments := (*received)[0].Arguments.(map[string]interface{})
+	if ids, ok := arguments["ids"].([]interface{}); !ok || len(ids) != 2 {
+		t.Errorf("Expected the hashes to be requested, got %v", arguments["ids"])
+	}
+}
+
+func TestTransmissionErrors(t *testing.T) {
+	server, _ := fakeTransmission(t, "admin", "secret")
+	defer server.Close()
+	client := NewTransmission(Config{URL: server.URL, Username: "admin", Password: "wrong"})
+	if err := client.Check(); err == nil {
+		t.Error("Expected invalid credentials to fail")
+	}
+	client = NewTransmission(Config{URL: server.URL, Username: "admin", Password: "secret"})
+	if err := client.call("unknown-method", nil, nil); err == nil {
+		t.Error("Expected an unknown method to fail")
+	}
+	if _, err := New(Config{Kind: "unknown"}); err == nil {
+		t.Error("Expected an unknown kind of client to be rejected")
+	}
+}
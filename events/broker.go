@@ -0,0 +1,97 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event is a notification published to the subscribers of a Broker.
+// Events with a Principal are only meant for that principal.
+type Event struct {
+	ID        int64
+	Name      string
+	Principal string
+	Data      json.RawMessage
+	Time      time.Time
+}
+
+// subscriberBuffer is the number of events a subscriber can lag behind
+// before being dropped
+const subscriberBuffer = 64
+
+// Broker is an in-process publish/subscribe hub, which keeps the last
+// published events so that subscribers can resume after a disconnection
+type Broker struct {
+	mutex       sync.Mutex
+	size        int
+	lastID      int64
+	replay      []Event
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroker creates a Broker keeping the last size events
+func NewBroker(size int) *Broker {
+	return &Broker{
+		size:        size,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish sends an event with the given name and data to every subscriber.
+// Subscribers too slow to receive it are dropped, and are expected to
+// subscribe again from the last event they received.
+func (b *Broker) Publish(name string, principal string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.lastID++
+	event := Event{
+		ID:        b.lastID,
+		Name:      name,
+		Principal: principal,
+		Data:      encoded,
+		Time:      time.Now(),
+	}
+	b.replay = append(b.replay, event)
+	if len(b.replay) > b.size {
+		b.replay = b.replay[len(b.replay)-b.size:]
+	}
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the kept events published after the event lastID, and a
+// channel receiving the events published from now on.
+// The channel is closed when the subscriber is dropped or cancel is called.
+func (b *Broker) Subscribe(lastID int64) ([]Event, <-chan Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var missed []Event
+	for _, event := range b.replay {
+		if event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+	subscriber := make(chan Event, subscriberBuffer)
+	b.subscribers[subscriber] = struct{}{}
+	cancel := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[subscriber]; ok {
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return missed, subscriber, cancel
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+func TestBrokerReplay(t *testing.T) {
+	b := NewBroker(2)
+	for i := 0; i < 3; i++ {
+		if err := b.Publish("shows.created", "", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missed, _, cancel := b.Subscribe(0)
+	defer cancel()
+	if len(missed) != 2 || missed[0].ID != 2 || missed[1].ID != 3 {
+		t.Errorf("Expected the last 2 events to be kept, got %v", missed)
+	}
+	missed, _, cancel = b.Subscribe(2)
+	defer cancel()
+	if len(missed) != 1 || missed[0].ID != 3 {
+		t.Errorf("Expected the events following event 2, got %v", missed)
+	}
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	b := NewBroker(1)
+	_, subscription, cancel := b.Subscribe(0)
+	defer cancel()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish("shows.updated", "", i)
+	}
+	received := 0
+	for range subscription {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("Expected the subscriber to be dropped after %d events, got %d", subscriberBuffer, received)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	b := NewBroker(10)
+	b.Record(datastore.Mutation{Action: "create", Type: "shows", ID: 1, After: map[string]interface{}{"title": "Twin Peaks"}})
+	b.Record(datastore.Mutation{Action: "delete", Type: "watchlist-entries", ID: 2, Before: map[string]interface{}{"principal": "alice"}})
+	b.Record(datastore.Mutation{Action: "scraped", Type: "torrents", ID: 3, After: map[string]interface{}{"seeders": 12}})
+	missed, _, cancel := b.Subscribe(0)
+	defer cancel()
+	if len(missed) != 3 {
+		t.Fatalf("Expected 2 events, got %v", missed)
+	}
+	if missed[0].Name != "shows.created" || missed[0].Principal != "" {
+		t.Errorf("Expected a public shows.created event, got %v", missed[0])
+	}
+	if missed[1].Name != "watchlist-entries.deleted" || missed[1].Principal != "alice" {
+		t.Errorf("Expected a watchlist-entries.deleted event for alice, got %v", missed[1])
+	}
+	if missed[2].Name != "torrents.scraped" || missed[2].Principal != "" {
+		t.Errorf("Expected a public torrents.scraped event, got %v", missed[2])
+	}
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"log"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+// actions maps the actions of datastore mutations to the names of events
+var actions = map[string]string{
+	"create":  "created",
+	"update":  "updated",
+	"delete":  "deleted",
+	"restore": "restored",
+	"purge":   "purged",
+}
+
+// Change is the data of the event published for a datastore mutation
+type Change struct {
+	Type       string                 `json:"type"`
+	ID         int                    `json:"id"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Actor      string                 `json:"actor,omitempty"`
+	Time       time.Time              `json:"time"`
+}
+
+// Describe returns the name of the event describing the given mutation,
+// such as "shows.created", with its data. The principal is set for
+// mutations of entities belonging to a principal, such as watchlist entries.
+func Describe(m datastore.Mutation) (name string, principal string, change Change) {
+	attributes := m.After
+	if attributes == nil {
+		attributes = m.Before
+	}
+	principal, _ = attributes["principal"].(string)
+	name = m.Type + "." + m.Action
+	if action, ok := actions[m.Action]; ok {
+		name = m.Type + "." + action
+	}
+	change = Change{
+		Type:       m.Type,
+		ID:         m.ID,
+		Attributes: attributes,
+		Actor:      m.Actor.Principal,
+		Time:       m.Time,
+	}
+	return name, principal, change
+}
+
+// Record publishes the event describing the given mutation. Mutations of
+// entities belonging to a principal are only published to that principal.
+// It is meant to be registered with datastore.OnMutation.
+func (b *Broker) Record(m datastore.Mutation) {
+	name, principal, change := Describe(m)
+	if err := b.Publish(name, principal, change); err != nil {
+		log.Println("Could not publish event:", err)
+	}
+}
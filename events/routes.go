@@ -0,0 +1,123 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+// heartbeat is the interval between the comments keeping idle streams open
+var heartbeat = 30 * time.Second
+
+// Routes returns the routes streaming the events of the given Broker, as
+// server-sent events or through WebSockets
+func Routes(b *Broker) router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/events",
+			Handler: Stream(b),
+			Method:  "GET",
+			Name:    "events.stream",
+		},
+		router.Route{
+			Path:    "/ws",
+			Handler: Socket(b),
+			Method:  "GET",
+			Name:    "events.socket",
+		},
+	}
+}
+
+// Stream is the HTTP endpoint used to follow the events of a Broker as
+// server-sent events.
+// The Last-Event-ID header, or the last_event_id parameter, resumes the
+// stream after the given event, as long as it is still kept by the Broker.
+// Events meant for another principal are left out.
+func Stream(b *Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			responses.SendError(w, herr.Error{
+				ID:     "streaming-unsupported",
+				Status: "500",
+				Title:  "Streaming Unsupported",
+			})
+			return
+		}
+		lastID, err := parseLastEventID(r)
+		if err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		principal := router.Principal(r)
+		missed, subscription, cancel := b.Subscribe(lastID)
+		defer cancel()
+
+		// Streams outlive the write timeout of the server, so the deadline
+		// is pushed back at each write
+		controller := http.NewResponseController(w)
+		controller.SetWriteDeadline(time.Now().Add(2 * heartbeat))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		for _, event := range missed {
+			writeEvent(w, event, principal)
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case event, open := <-subscription:
+				if !open {
+					return
+				}
+				writeEvent(w, event, principal)
+			case <-ticker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			controller.SetWriteDeadline(time.Now().Add(2 * heartbeat))
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes the given event to w, unless it is meant for another
+// principal
+func writeEvent(w http.ResponseWriter, event Event, principal string) {
+	if event.Principal != "" && event.Principal != principal {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Name, event.Data)
+}
+
+func parseLastEventID(r *http.Request) (int64, *herr.Error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, &herr.Error{
+			ID:     "invalid-parameter",
+			Status: "400",
+			Title:  "Invalid query parameter",
+			Detail: "last_event_id must be the ID of an event",
+			Source: herr.ErrorSource{
+				Parameter: "last_event_id",
+			},
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/torrent-viewer/backend/router"
+)
+
+// guard authenticates users by the Username header
+func guard(r *http.Request) (string, bool) {
+	username := r.Header.Get("Username")
+	return username, username != ""
+}
+
+func newServer(b *Broker) *httptest.Server {
+	r := router.NewRouter()
+	r.Use(router.FirewallMiddleware(router.FirewallConfig{
+		Guard: guard,
+		Only:  []string{"^/events$", "^/ws$"},
+	}))
+	r.AddRoutes(Routes(b))
+	return httptest.NewServer(r)
+}
+
+// follow opens the event stream as the given user, and returns the first
+// lines following the given event
+func follow(t *testing.T, url string, username string, lastID string, count int) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Username", username)
+	if lastID != "" {
+		request.Header.Set("Last-Event-ID", lastID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %s", contentType)
+	}
+	lines := []string{}
+	scanner := bufio.NewScanner(response.Body)
+	for len(lines) < count && scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestStream(t *testing.T) {
+	b := NewBroker(10)
+	server := newServer(b)
+	defer server.Close()
+	url := fmt.Sprintf("%s/events", server.URL)
+
+	b.Publish("shows.created", "", map[string]int{"id": 1})
+	b.Publish("watchlist-entries.created", "alice", map[string]int{"id": 1})
+	b.Publish("shows.updated", "", map[string]int{"id": 1})
+
+	lines := follow(t, url, "bob", "1", 3)
+	expected := []string{"id: 3", "event: shows.updated", `data: {"id":1}`}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+	lines = follow(t, url, "alice", "1", 2)
+	if len(lines) != 2 || lines[1] != "event: watchlist-entries.created" {
+		t.Errorf("Expected the event of alice, got %q", lines)
+	}
+
+	// Events published while the stream is open are sent as well
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		b.Publish("episodes.deleted", "", map[string]int{"id": 2})
+	}()
+	lines = follow(t, url, "bob", "3", 2)
+	if len(lines) != 2 || lines[1] != "event: episodes.deleted" {
+		t.Errorf("Expected the published event, got %q", lines)
+	}
+}
+
+func TestStreamInvalidLastEventID(t *testing.T) {
+	server := newServer(NewBroker(10))
+	defer server.Close()
+	request, _ := http.NewRequest("GET", fmt.Sprintf("%s/events?last_event_id=last", server.URL), nil)
+	request.Header.Set("Username", "bob")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+}
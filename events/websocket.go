@@ -0,0 +1,239 @@
+package events
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/router"
+)
+
+const (
+	// writeWait is the time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+	// maxMessageSize is the maximum size of the messages sent by the peer
+	maxMessageSize = 4096
+	// maxSubscriptions is the maximum number of IDs a connection follows
+	maxSubscriptions = 1000
+)
+
+var (
+	// pingPeriod is the interval between pings sent to the peer
+	pingPeriod = 30 * time.Second
+	// pongWait is the time allowed to read the next pong from the peer
+	pongWait = 60 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Command is a message sent by the peer of a WebSocket, to subscribe to or
+// unsubscribe from the changes of the given resources
+type Command struct {
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	IDs    []int  `json:"ids"`
+}
+
+// message is a JSON API document sent to the peer of a WebSocket
+type message struct {
+	Data   *resourceObject        `json:"data,omitempty"`
+	Errors herr.Errors            `json:"errors,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+type resourceObject struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// subscriptions are the IDs followed by a connection, by resource type
+type subscriptions map[string]map[int]bool
+
+func (s subscriptions) count() int {
+	count := 0
+	for _, ids := range s {
+		count += len(ids)
+	}
+	return count
+}
+
+// matches reports whether the given change concerns a followed resource.
+// Following a show also follows its seasons, its episodes and their torrents.
+func (s subscriptions) matches(change Change) bool {
+	if s[change.Type][change.ID] {
+		return true
+	}
+	showID, ok := change.Attributes["show_id"].(float64)
+	return ok && s["shows"][int(showID)]
+}
+
+// Socket is the HTTP endpoint upgrading connections to WebSockets, through
+// which peers follow the changes of the resources they subscribe to.
+// Peers too slow to receive the changes are disconnected.
+func Socket(b *Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := router.Principal(r)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader already replied with an error
+			return
+		}
+		// Peers only receive the changes following their connection
+		_, subscription, cancel := b.Subscribe(math.MaxInt64)
+		defer cancel()
+
+		commands := make(chan Command)
+		closed := make(chan struct{})
+		go readCommands(conn, commands, closed)
+		defer conn.Close()
+
+		followed := make(subscriptions)
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			var out *message
+			select {
+			case command := <-commands:
+				reply := apply(followed, command)
+				out = &reply
+			case event, open := <-subscription:
+				if !open {
+					closeConn(conn, websocket.CloseTryAgainLater, "too slow to receive changes")
+					return
+				}
+				out = changeMessage(event, principal, followed)
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+			if out != nil {
+				conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := conn.WriteJSON(out); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// readCommands decodes the commands sent by the peer until the connection
+// is closed or the peer stops answering pings.
+// Peers sending commands faster than they are applied are disconnected.
+func readCommands(conn *websocket.Conn, commands chan<- Command, closed chan<- struct{}) {
+	defer close(closed)
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var command Command
+		if err := json.Unmarshal(data, &command); err != nil {
+			// Invalid commands are answered with an error
+			command = Command{}
+		}
+		select {
+		case commands <- command:
+		case <-time.After(writeWait):
+			return
+		}
+	}
+}
+
+// apply updates the followed resources with the given command, and returns
+// the reply to send to the peer
+func apply(followed subscriptions, command Command) message {
+	if command.Type == "" || len(command.IDs) == 0 {
+		return errorMessage("type and ids are required")
+	}
+	switch command.Action {
+	case "subscribe":
+		if followed[command.Type] == nil {
+			followed[command.Type] = make(map[int]bool)
+		}
+		for _, id := range command.IDs {
+			followed[command.Type][id] = true
+		}
+		if followed.count() > maxSubscriptions {
+			for _, id := range command.IDs {
+				delete(followed[command.Type], id)
+			}
+			return errorMessage("at most " + strconv.Itoa(maxSubscriptions) + " resources can be followed")
+		}
+	case "unsubscribe":
+		for _, id := range command.IDs {
+			delete(followed[command.Type], id)
+		}
+	default:
+		return errorMessage("action must be subscribe or unsubscribe")
+	}
+	return message{
+		Meta: map[string]interface{}{
+			"action": command.Action + "d",
+			"type":   command.Type,
+			"ids":    command.IDs,
+		},
+	}
+}
+
+// changeMessage returns the message describing the given event, or nil if
+// the peer does not follow the changed resource or may not see it
+func changeMessage(event Event, principal string, followed subscriptions) *message {
+	if event.Principal != "" && event.Principal != principal {
+		return nil
+	}
+	var change Change
+	if err := json.Unmarshal(event.Data, &change); err != nil || change.Type == "" {
+		return nil
+	}
+	if !followed.matches(change) {
+		return nil
+	}
+	return &message{
+		Data: &resourceObject{
+			Type:       change.Type,
+			ID:         strconv.Itoa(change.ID),
+			Attributes: change.Attributes,
+		},
+		Meta: map[string]interface{}{
+			"event":    event.Name,
+			"event_id": event.ID,
+			"actor":    change.Actor,
+		},
+	}
+}
+
+func errorMessage(detail string) message {
+	return message{
+		Errors: herr.Errors{
+			herr.Error{
+				ID:     "invalid-command",
+				Status: "400",
+				Title:  "Invalid Command",
+				Detail: detail,
+			},
+		},
+	}
+}
+
+// closeConn tells the peer why the connection is closed
+func closeConn(conn *websocket.Conn, code int, reason string) {
+	deadline := time.Now().Add(writeWait)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+func dial(t *testing.T, url string, username string) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	if username != "" {
+		header.Set("Username", username)
+	}
+	return websocket.DefaultDialer.Dial(strings.Replace(url, "http", "ws", 1)+"/ws", header)
+}
+
+// receive reads the next message sent on conn
+func receive(t *testing.T, conn *websocket.Conn) message {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var m message
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSocket(t *testing.T) {
+	b := NewBroker(10)
+	server := newServer(b)
+	defer server.Close()
+
+	if _, response, err := dial(t, server.URL, ""); err == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected anonymous connections to be rejected")
+	}
+	conn, _, err := dial(t, server.URL, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"action": "follow"}`))
+	if m := receive(t, conn); len(m.Errors) != 1 || m.Errors[0].ID != "invalid-command" {
+		t.Errorf("Expected an invalid command error, got %v", m)
+	}
+	conn.WriteJSON(Command{Action: "subscribe", Type: "shows", IDs: []int{1}})
+	if m := receive(t, conn); m.Meta["action"] != "subscribed" {
+		t.Fatalf("Expected the subscription to be acknowledged, got %v", m)
+	}
+
+	b.Record(datastore.Mutation{Action: "update", Type: "shows", ID: 2, After: map[string]interface{}{"title": "Northern Exposure"}})
+	b.Record(datastore.Mutation{Action: "create", Type: "watched-marks", ID: 1, After: map[string]interface{}{"principal": "alice", "show_id": 1}})
+	b.Record(datastore.Mutation{Action: "update", Type: "shows", ID: 1, After: map[string]interface{}{"title": "Twin Peaks"}})
+	b.Record(datastore.Mutation{Action: "create", Type: "episodes", ID: 3, After: map[string]interface{}{"show_id": 1}})
+
+	m := receive(t, conn)
+	if m.Data == nil || m.Data.Type != "shows" || m.Data.ID != "1" || m.Meta["event"] != "shows.updated" {
+		t.Errorf("Expected the update of show 1, got %v", m)
+	}
+	m = receive(t, conn)
+	if m.Data == nil || m.Data.Type != "episodes" || m.Data.ID != "3" {
+		t.Errorf("Expected the creation of an episode of show 1, got %v", m)
+	}
+
+	conn.WriteJSON(Command{Action: "unsubscribe", Type: "shows", IDs: []int{1}})
+	if m := receive(t, conn); m.Meta["action"] != "unsubscribed" {
+		t.Fatalf("Expected the unsubscription to be acknowledged, got %v", m)
+	}
+}
+
+func TestSocketTorrents(t *testing.T) {
+	b := NewBroker(10)
+	server := newServer(b)
+	defer server.Close()
+	conn, _, err := dial(t, server.URL, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(Command{Action: "subscribe", Type: "torrents", IDs: []int{5}})
+	receive(t, conn)
+	conn.WriteJSON(Command{Action: "subscribe", Type: "shows", IDs: []int{1}})
+	receive(t, conn)
+
+	b.Record(datastore.Mutation{Action: "progressed", Type: "torrents", ID: 4, After: map[string]interface{}{"download_progress": 0.2}})
+	b.Record(datastore.Mutation{Action: "progressed", Type: "torrents", ID: 5, After: map[string]interface{}{"download_state": "downloading", "download_progress": 0.5}})
+	b.Record(datastore.Mutation{Action: "scraped", Type: "torrents", ID: 6, After: map[string]interface{}{"show_id": 1, "seeders": 12}})
+
+	m := receive(t, conn)
+	if m.Data == nil || m.Data.ID != "5" || m.Meta["event"] != "torrents.progressed" || m.Data.Attributes["download_progress"] != 0.5 {
+		t.Errorf("Expected the progress of torrent 5, got %v", m)
+	}
+	m = receive(t, conn)
+	if m.Data == nil || m.Data.ID != "6" || m.Meta["event"] != "torrents.scraped" || m.Data.Attributes["seeders"] != float64(12) {
+		t.Errorf("Expected the seeders of a torrent of show 1, got %v", m)
+	}
+}
+
+func TestSocketSlowPeer(t *testing.T) {
+	b := NewBroker(10)
+	server := newServer(b)
+	defer server.Close()
+	conn, _, err := dial(t, server.URL, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(Command{Action: "subscribe", Type: "shows", IDs: []int{1}})
+	receive(t, conn)
+
+	// Flood the connection without reading it, so that it falls behind
+	payload := map[string]interface{}{"summary": strings.Repeat("x", 64*1024)}
+	for i := 0; i < 8*subscriberBuffer; i++ {
+		b.Record(datastore.Mutation{Action: "update", Type: "shows", ID: 1, After: payload})
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var m message
+		err := conn.ReadJSON(&m)
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+			t.Errorf("Expected the connection to be closed for being too slow, got %v", err)
+		}
+		break
+	}
+}
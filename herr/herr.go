@@ -48,6 +48,34 @@ var DuplicateEntryError = Error{
 	},
 }
 
+var ConflictingEntryError = Error{
+	ID:     "conflicting-entry",
+	Status: "409",
+	Title:  "Conflicting Entry",
+	Detail: "The resource conflicts with an existing resource",
+}
+
+var PreconditionFailedError = Error{
+	ID:     "precondition-failed",
+	Status: "412",
+	Title:  "Precondition Failed",
+	Detail: "The resource has been modified since it was fetched",
+}
+
+var UnauthorizedError = Error{
+	ID:     "unauthorized",
+	Status: "401",
+	Title:  "Unauthorized",
+	Detail: "The resource is only available to authenticated users",
+}
+
+var TooManyRequestsError = Error{
+	ID:     "too-many-requests",
+	Status: "429",
+	Title:  "Too Many Requests",
+	Detail: "The rate limit has been exceeded, retry later",
+}
+
 // Error allow herr.Error to be considered a go error
 func (e Error) Error() string {
 	return fmt.Sprintf("HTTP %s: %s (%s)", e.Code, e.Title, e.ID)
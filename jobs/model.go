@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"time"
+)
+
+// States of a Job
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateDead      = "dead"
+	StateCancelled = "cancelled"
+)
+
+// Job is a unit of background work, run by the Handler registered for its
+// type with its payload.
+// Failed jobs are queued again until MaxAttempts is reached, then they are
+// dead. A running job whose lock expires was abandoned by a crashed worker,
+// and is run again.
+type Job struct {
+	ID          int        `jsonapi:"primary,jobs" gorm:"primary_key"`
+	CreatedAt   time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt   time.Time  `jsonapi:"attr,updated_at"`
+	Type        string     `jsonapi:"attr,type" sql:"index"`
+	Payload     string     `jsonapi:"attr,payload" sql:"type:text"`
+	State       string     `jsonapi:"attr,state" sql:"index"`
+	Attempts    int        `jsonapi:"attr,attempts"`
+	MaxAttempts int        `jsonapi:"attr,max_attempts"`
+	RunAt       time.Time  `jsonapi:"attr,run_at" sql:"index"`
+	LockedBy    string     `jsonapi:"attr,locked_by"`
+	LockedUntil *time.Time `jsonapi:"attr,locked_until"`
+	LastError   string     `jsonapi:"attr,last_error" sql:"type:text"`
+	FinishedAt  *time.Time `jsonapi:"attr,finished_at"`
+}
+
+type Jobs []*Job
+
+type JobResource struct{}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+func (j Job) GetID() int {
+	return j.ID
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+// Handler runs a job with its JSON payload
+type Handler func(payload []byte) error
+
+// DefaultMaxAttempts is the number of attempts of the enqueued jobs
+const DefaultMaxAttempts = 5
+
+var (
+	mutex    sync.RWMutex
+	handlers = make(map[string]Handler)
+)
+
+// owner identifies this instance when locking jobs
+var owner = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}()
+
+// Register sets the Handler running the jobs of the given type.
+// Workers only run the jobs whose type is registered.
+func Register(jobType string, handler Handler) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	handlers[jobType] = handler
+}
+
+func handler(jobType string) Handler {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return handlers[jobType]
+}
+
+func registeredTypes() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for jobType := range handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+// Enqueue queues a job of the given type, to be run as soon as possible
+func Enqueue(jobType string, payload interface{}) (*Job, error) {
+	return EnqueueAt(jobType, payload, time.Now())
+}
+
+// EnqueueAt queues a job of the given type, to be run at the given time.
+// Jobs are stored directly, so that they are not mutations themselves.
+func EnqueueAt(jobType string, payload interface{}, at time.Time) (*Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := Job{
+		Type:        jobType,
+		Payload:     string(encoded),
+		State:       StateQueued,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       at,
+	}
+	if err := datastore.Conn.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
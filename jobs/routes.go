@@ -0,0 +1,137 @@
+package jobs
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var filters = map[string]string{
+	"type":  "type",
+	"state": "state",
+}
+
+// JobsList is the HTTP endpoint used to list Job instances, newest first
+func (JobResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Jobs
+	scopes := requests.ParseFilters(r, filters)
+	page, err := requests.Paginate(&Job{}, r, scopes...)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	scopes = append(scopes, datastore.Order("id desc"))
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, scopes...); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// JobsView is the HTTP endpoint used to show a Job instance by ID
+func (JobResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var job Job
+	if err := datastore.FetchEntity(&job, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &job, http.StatusOK)
+}
+
+// Routes returns the routes of the jobs that are not part of the resource
+// itself
+func Routes() router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/jobs/{id:[0-9]+}/retry",
+			Handler: Retry,
+			Method:  "POST",
+			Name:    "jobs.retry",
+		},
+		router.Route{
+			Path:    "/jobs/{id:[0-9]+}/cancel",
+			Handler: Cancel,
+			Method:  "POST",
+			Name:    "jobs.cancel",
+		},
+	}
+}
+
+// Retry is the HTTP endpoint used to queue a dead or cancelled Job instance
+// again, with all its attempts
+func Retry(w http.ResponseWriter, r *http.Request) {
+	transition(w, r, []string{StateDead, StateCancelled}, map[string]interface{}{
+		"state":       StateQueued,
+		"attempts":    0,
+		"run_at":      time.Now(),
+		"finished_at": nil,
+	})
+}
+
+// Cancel is the HTTP endpoint used to cancel a queued or running Job
+// instance. A running job is not interrupted, but its outcome is ignored.
+func Cancel(w http.ResponseWriter, r *http.Request) {
+	transition(w, r, []string{StateQueued, StateRunning}, map[string]interface{}{
+		"state":        StateCancelled,
+		"locked_by":    "",
+		"locked_until": nil,
+		"finished_at":  time.Now(),
+	})
+}
+
+// transition updates the columns of the requested Job instance if it is in
+// one of the given states, and sends it back
+func transition(w http.ResponseWriter, r *http.Request, from []string, columns map[string]interface{}) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var job Job
+	if err := datastore.FetchEntity(&job, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	columns["updated_at"] = time.Now()
+	updated := datastore.Conn.Model(&Job{}).
+		Where("id = ? AND state IN (?)", id, from).
+		UpdateColumns(columns)
+	if updated.Error != nil {
+		responses.SendError(w, herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: updated.Error.Error(),
+		})
+		return
+	}
+	if updated.RowsAffected != 1 {
+		responses.SendError(w, herr.Error{
+			ID:     "invalid-state",
+			Status: "409",
+			Title:  "Invalid State",
+			Detail: fmt.Sprintf("the job is %s", job.State),
+		})
+		return
+	}
+	if err := datastore.FetchEntity(&job, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &job, http.StatusOK)
+}
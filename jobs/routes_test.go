@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-jobs-test.db")
+	datastore.Conn.AutoMigrate(&Job{})
+	r := router.NewRouter()
+	r.AddResource("jobs", JobResource{})
+	r.AddRoutes(Routes())
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/jobs", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&Job{})
+	os.Exit(ret)
+}
+
+func post(t *testing.T, url string) (*http.Response, string) {
+	response, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Data struct {
+			Attributes struct {
+				State string `json:"state"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if response.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return response, document.Data.Attributes.State
+}
+
+func TestJobsList(t *testing.T) {
+	job, err := Enqueue("test.list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.Get(fmt.Sprintf("%s?filter[type]=test.list", baseURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var document struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Data) != 1 || document.Data[0].ID != fmt.Sprintf("%d", job.ID) {
+		t.Errorf("Expected job %d, got %v", job.ID, document.Data)
+	}
+}
+
+func TestJobsCancelRetry(t *testing.T) {
+	job, err := Enqueue("test.admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/%d", baseURL, job.ID)
+
+	response, _ := post(t, url+"/retry")
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusConflict, response.StatusCode)
+	}
+	response, state := post(t, url+"/cancel")
+	if response.StatusCode != http.StatusOK || state != StateCancelled {
+		t.Errorf("Expected the job to be cancelled, got HTTP %d and state %q", response.StatusCode, state)
+	}
+	response, state = post(t, url+"/retry")
+	if response.StatusCode != http.StatusOK || state != StateQueued {
+		t.Errorf("Expected the job to be queued, got HTTP %d and state %q", response.StatusCode, state)
+	}
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+var (
+	// visibility is the time a running job stays locked by its worker.
+	// The lock is extended while the job runs, so it only expires when the
+	// worker crashed.
+	visibility = 5 * time.Minute
+	// retryDelay is the delay before the second attempt of a failed job,
+	// which doubles at each following attempt
+	retryDelay = 30 * time.Second
+	// maxRetryDelay bounds the delay between two attempts
+	maxRetryDelay = 6 * time.Hour
+)
+
+// Work runs the jobs of the registered types with the given number of
+// workers, each looking for a job every poll interval when idle.
+// It never returns.
+func Work(concurrency int, poll time.Duration) {
+	for i := 1; i < concurrency; i++ {
+		go work(poll)
+	}
+	work(poll)
+}
+
+func work(poll time.Duration) {
+	for {
+		job := claim()
+		if job == nil {
+			time.Sleep(poll)
+			continue
+		}
+		run(job)
+	}
+}
+
+// claim locks the next job due, and returns it.
+// It returns nil if there is no job to run.
+func claim() *Job {
+	types := registeredTypes()
+	if len(types) == 0 {
+		return nil
+	}
+	now := time.Now()
+	var due Jobs
+	err := datastore.Conn.
+		Where("type IN (?) AND ((state = ? AND run_at <= ?) OR (state = ? AND locked_until < ?))", types, StateQueued, now, StateRunning, now).
+		Order("run_at").
+		Limit(10).
+		Find(&due).Error
+	if err != nil {
+		log.Println("Could not load jobs:", err)
+		return nil
+	}
+	for _, job := range due {
+		// A job abandoned at its last attempt is not run again
+		if job.State == StateRunning && job.Attempts >= job.MaxAttempts {
+			finish(job, fmt.Errorf("worker %s stopped running the job", job.LockedBy))
+			continue
+		}
+		until := now.Add(visibility)
+		claimed := datastore.Conn.Model(&Job{}).
+			Where("id = ? AND state = ? AND attempts = ?", job.ID, job.State, job.Attempts).
+			UpdateColumns(map[string]interface{}{
+				"state":        StateRunning,
+				"attempts":     job.Attempts + 1,
+				"locked_by":    owner,
+				"locked_until": until,
+				"updated_at":   now,
+			})
+		if claimed.Error != nil || claimed.RowsAffected != 1 {
+			continue
+		}
+		job.State = StateRunning
+		job.Attempts++
+		job.LockedBy = owner
+		job.LockedUntil = &until
+		return job
+	}
+	return nil
+}
+
+// run runs a claimed job, extending its lock until it is done
+func run(job *Job) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				datastore.Conn.Model(&Job{}).
+					Where("id = ? AND state = ? AND locked_by = ? AND attempts = ?", job.ID, StateRunning, owner, job.Attempts).
+					UpdateColumn("locked_until", time.Now().Add(visibility))
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := call(handler(job.Type), []byte(job.Payload))
+	close(done)
+	finish(job, err)
+}
+
+// call runs the handler, turning panics into errors
+func call(h Handler, payload []byte) (err error) {
+	if h == nil {
+		return fmt.Errorf("no handler registered")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(payload)
+}
+
+// finish records the outcome of the last attempt of a running job.
+// Failed jobs are queued again with an exponential backoff, or are dead after
+// their last attempt. Jobs cancelled while running are left cancelled.
+func finish(job *Job, err error) {
+	now := time.Now()
+	columns := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+		"updated_at":   now,
+	}
+	switch {
+	case err == nil:
+		columns["state"] = StateSucceeded
+		columns["finished_at"] = now
+	case job.Attempts >= job.MaxAttempts:
+		columns["state"] = StateDead
+		columns["last_error"] = err.Error()
+		columns["finished_at"] = now
+	default:
+		delay := retryDelay << uint(job.Attempts-1)
+		if delay > maxRetryDelay || delay < 0 {
+			delay = maxRetryDelay
+		}
+		columns["state"] = StateQueued
+		columns["last_error"] = err.Error()
+		columns["run_at"] = now.Add(delay)
+	}
+	finished := datastore.Conn.Model(&Job{}).
+		Where("id = ? AND state = ? AND attempts = ?", job.ID, StateRunning, job.Attempts).
+		UpdateColumns(columns)
+	if finished.Error != nil {
+		log.Printf("Could not record the outcome of job %d: %s\n", job.ID, finished.Error)
+	} else if err != nil {
+		log.Printf("Job %d (%s) failed at attempt %d: %s\n", job.ID, job.Type, job.Attempts, err)
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+func reload(t *testing.T, job *Job) Job {
+	var stored Job
+	if err := datastore.FetchEntity(&stored, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestWorkerRun(t *testing.T) {
+	var received struct {
+		ShowID int `json:"show_id"`
+	}
+	Register("test.run", func(payload []byte) error {
+		return json.Unmarshal(payload, &received)
+	})
+	job, err := Enqueue("test.run", map[string]int{"show_id": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed := claim()
+	if claimed == nil || claimed.ID != job.ID {
+		t.Fatalf("Expected job %d to be claimed, got %v", job.ID, claimed)
+	}
+	if claim() != nil {
+		t.Error("Expected a running job not to be claimed twice")
+	}
+	run(claimed)
+	if received.ShowID != 42 {
+		t.Errorf("Expected the payload to be received, got %v", received)
+	}
+	if stored := reload(t, job); stored.State != StateSucceeded || stored.FinishedAt == nil {
+		t.Errorf("Expected the job to succeed, got %v", stored)
+	}
+}
+
+func TestWorkerRetries(t *testing.T) {
+	retryDelay = 0
+	calls := 0
+	Register("test.fail", func(payload []byte) error {
+		calls++
+		if calls%2 == 0 {
+			panic("unexpected payload")
+		}
+		return errors.New("tracker unreachable")
+	})
+	job, err := Enqueue("test.fail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for claimed := claim(); claimed != nil; claimed = claim() {
+		run(claimed)
+	}
+	stored := reload(t, job)
+	if stored.State != StateDead || stored.Attempts != DefaultMaxAttempts || calls != DefaultMaxAttempts {
+		t.Errorf("Expected the job to be dead after %d attempts, got %v after %d calls", DefaultMaxAttempts, stored, calls)
+	}
+	if stored.LastError != "tracker unreachable" {
+		t.Errorf("Expected the last error to be recorded, got %q", stored.LastError)
+	}
+}
+
+func TestWorkerCrash(t *testing.T) {
+	Register("test.crash", func(payload []byte) error {
+		return nil
+	})
+	job, err := Enqueue("test.crash", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed := claim(); claimed == nil || claimed.ID != job.ID {
+		t.Fatalf("Expected job %d to be claimed, got %v", job.ID, claimed)
+	}
+	// The worker crashes without finishing the job, whose lock expires
+	datastore.Conn.Model(&Job{}).Where("id = ?", job.ID).UpdateColumn("locked_until", time.Now().Add(-time.Second))
+	claimed := claim()
+	if claimed == nil || claimed.ID != job.ID || claimed.Attempts != 2 {
+		t.Fatalf("Expected job %d to be claimed again, got %v", job.ID, claimed)
+	}
+	run(claimed)
+	if stored := reload(t, job); stored.State != StateSucceeded {
+		t.Errorf("Expected the job to succeed, got %v", stored)
+	}
+}
@@ -2,39 +2,35 @@ package main
 
 import (
 	"log"
-	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	// "github.com/gorilla/handlers"
+	"github.com/torrent-viewer/backend/auth"
 	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/events"
+	"github.com/torrent-viewer/backend/jobs"
+	"github.com/torrent-viewer/backend/metadata"
+	"github.com/torrent-viewer/backend/resources/audit"
+	"github.com/torrent-viewer/backend/resources/calendar"
+	"github.com/torrent-viewer/backend/resources/download"
+	"github.com/torrent-viewer/backend/resources/episode"
+	"github.com/torrent-viewer/backend/resources/profile"
+	"github.com/torrent-viewer/backend/resources/season"
 	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/resources/torrent"
+	"github.com/torrent-viewer/backend/resources/watch"
+	"github.com/torrent-viewer/backend/resources/webhook"
 	"github.com/torrent-viewer/backend/router"
+	"github.com/torrent-viewer/backend/scheduler"
+	"github.com/torrent-viewer/backend/scraper"
+	"github.com/torrent-viewer/backend/server"
 )
 
-func BasicAuth(r *http.Request) bool {
-	if usernames, ok := r.Header["Username"]; ok {
-		if len(usernames) != 1 {
-			return false
-		}
-		if usernames[0] != "admin" {
-			return false
-		}
-	} else {
-		return false
-	}
-	if passwords, ok := r.Header["Password"]; ok {
-		if len(passwords) != 1 {
-			return false
-		}
-		if passwords[0] != "password" {
-			return false
-		}
-	} else {
-		return false
-	}
-	return true
-}
+// adminRoutes are the patterns of the routes restricted to administrators:
+// hard purges, merges of duplicates and the job queue
+var adminRoutes = []string{"^/[a-z-]+/[0-9]+/purge$", "^/torrents/deduplicate$", "^/jobs"}
 
 func main() {
 	dbDriver := os.Getenv("TV_DB_DRIVER")
@@ -55,20 +51,192 @@ func main() {
 			}
 		}
 	}
-	datastore.Conn.AutoMigrate(&show.Show{})
+	datastore.Conn.AutoMigrate(&show.Show{}, &season.Season{}, &episode.Episode{}, &torrent.Torrent{}, &profile.Profile{}, &watch.Entry{}, &watch.Mark{}, &calendar.Token{}, &download.Client{}, &webhook.Webhook{}, &webhook.Delivery{}, &audit.Event{}, &jobs.Job{}, &datastore.Lock{})
+	datastore.Register(&show.Show{}, &season.Season{}, &episode.Episode{}, &torrent.Torrent{}, &profile.Profile{})
+	datastore.OnMutation(audit.Record)
+	broker := events.NewBroker(1000)
+	datastore.OnMutation(broker.Record)
+	datastore.OnMutation(webhook.Enqueue)
+	datastore.OnMutation(torrent.Match)
+	trashRetention := 30 * 24 * time.Hour
+	if retention := os.Getenv("TV_TRASH_RETENTION"); retention != "" {
+		trashRetention, err = time.ParseDuration(retention)
+		if err != nil {
+			log.Fatal("Invalid TV_TRASH_RETENTION: ", err)
+		}
+	}
+	go datastore.CollectTrash(time.Hour, trashRetention, &show.Show{}, &season.Season{}, &episode.Episode{}, &torrent.Torrent{}, &profile.Profile{}, &download.Client{}, &webhook.Webhook{})
 	r := router.NewRouter()
 	r.Use(router.LoggingMiddleware)
-	//r.Use(handlers.CORS())
+	r.Use(router.RequestIDMiddleware)
 	acceptedTypes := []string{
 		"application/vnd.api+json",
 		"application/vnd.api+json; charset=UTF-8",
 		"application/vnd.api+json; charset=utf-8",
 	}
 	r.Use(router.ContentTypeMiddleware(acceptedTypes))
+	rateLimits := router.RateLimitConfig{
+		Default: router.Limit{Rate: 10, Burst: 100},
+		Routes: map[string]router.Limit{
+			"shows.store": {Rate: 1.0 / 6, Burst: 10},
+			"shows.sync":  {Rate: 1.0 / 60, Burst: 5},
+		},
+	}
+	if os.Getenv("TV_RATE_LIMIT_STORE") == "database" {
+		datastore.Conn.AutoMigrate(&datastore.RateLimit{})
+		rateLimits.Store = datastore.RateLimits{}
+		hourly, _ := scheduler.Parse("@hourly")
+		go scheduler.Start(scheduler.Job{
+			Name:     "rate-limits-prune",
+			Schedule: hourly,
+			Run: func() {
+				if err := datastore.PruneRateLimits(time.Now().Add(-24 * time.Hour)); err != nil {
+					log.Println("Could not prune rate limits:", err)
+				}
+			},
+		})
+	}
+	// Users are given as username:password pairs, admin:password by default
+	users := map[string]string{"admin": "password"}
+	if list := os.Getenv("TV_USERS"); list != "" {
+		users, err = auth.ParseUsers(list)
+		if err != nil {
+			log.Fatal("Invalid TV_USERS: ", err)
+		}
+	}
+	guard := auth.Users(users)
+	// Middlewares used first run last, so the rate limiter knows the users
+	// authenticated by the firewalls
+	r.Use(router.RateLimitMiddleware(rateLimits))
+	// Both firewalls share the failed authentications of each IP address
+	failures := router.Limit{Rate: 1.0 / 60, Burst: 10}
+	failureStore := rateLimits.Store
+	if failureStore == nil {
+		failureStore = router.NewMemoryRateLimitStore()
+	}
+	r.Use(router.FirewallMiddleware(router.FirewallConfig{
+		Guard:        guard,
+		Failures:     failures,
+		FailureStore: failureStore,
+		Only:         []string{"^/shows", "^/seasons", "^/episodes", "^/torrents", "^/quality-profiles", "^/download-clients", "^/webhooks", "^/audit-events", "^/jobs", "^/me/", "^/calendar$", "^/events$", "^/ws$"},
+	}))
+	admins := []string{"admin"}
+	if principals := os.Getenv("TV_ADMINS"); principals != "" {
+		admins = strings.Split(principals, ",")
+	}
 	r.Use(router.FirewallMiddleware(router.FirewallConfig{
-		Guard: BasicAuth,
-		Only: []string{"^/shows"},
+		Guard:        auth.Restrict(guard, admins...),
+		Failures:     failures,
+		FailureStore: failureStore,
+		Only:         adminRoutes,
 	}))
+	// Streams are sent as they are, and the calendar token is never cached
+	r.Use(router.CompressionMiddleware(router.CompressionConfig{
+		MinSize: 1024,
+		Except:  []string{"events.stream", "events.socket"},
+	}))
+	r.Use(router.CacheMiddleware(router.CacheConfig{
+		Default: "private, no-cache",
+		Routes: map[string]string{
+			"calendar.export":         "private, max-age=900",
+			"me.calendar-token.view":  "no-store",
+			"me.calendar-token.renew": "no-store",
+		},
+	}))
+	// Used last so that it runs first, and answers preflight requests
+	if origins := os.Getenv("TV_CORS_ORIGINS"); origins != "" {
+		r.Use(router.CORSMiddleware(router.CORSConfig{
+			Origins:     strings.Split(origins, ","),
+			Credentials: os.Getenv("TV_CORS_CREDENTIALS") == "true",
+			MaxAge:      10 * time.Minute,
+		}))
+	}
 	r.AddResource("shows", show.ShowResource{})
-	log.Fatal(http.ListenAndServe(":8080", r))
+	r.AddResource("seasons", season.SeasonResource{})
+	r.AddResource("episodes", episode.EpisodeResource{})
+	r.AddResource("torrents", torrent.TorrentResource{})
+	r.AddRoutes(torrent.Routes())
+	r.AddResource("quality-profiles", profile.ProfileResource{})
+	r.AddResource("download-clients", download.ClientResource{})
+	r.AddRoutes(download.Routes())
+	r.AddResource("webhooks", webhook.WebhookResource{})
+	r.AddRoutes(webhook.Routes())
+	r.AddResource("audit-events", audit.AuditResource{})
+	r.AddResource("jobs", jobs.JobResource{})
+	r.AddRoutes(jobs.Routes())
+	r.AddRoutes(watch.Routes())
+	r.AddRoutes(calendar.Routes())
+	r.AddRoutes(events.Routes(broker))
+	metadataURL := os.Getenv("TV_METADATA_URL")
+	if metadataURL == "" {
+		metadataURL = "https://api.tvmaze.com"
+	}
+	provider := metadata.NewTVMaze(metadataURL)
+	r.AddRoutes(metadata.Routes(provider))
+	jobs.Register(metadata.SyncJob, metadata.SyncHandler(provider))
+	jobs.Register(webhook.DeliveryJob, webhook.DeliveryHandler)
+	syncSchedule := "0 */6 * * *"
+	if schedule := os.Getenv("TV_SYNC_SCHEDULE"); schedule != "" {
+		syncSchedule = schedule
+	}
+	schedule, err := scheduler.Parse(syncSchedule)
+	if err != nil {
+		log.Fatal("Invalid TV_SYNC_SCHEDULE: ", err)
+	}
+	go scheduler.Start(scheduler.Job{
+		Name:     "metadata-refresh",
+		Schedule: schedule,
+		Run: func() {
+			metadata.EnqueueRefresh()
+		},
+	})
+	scrapeSchedule := "*/30 * * * *"
+	if schedule := os.Getenv("TV_SCRAPE_SCHEDULE"); schedule != "" {
+		scrapeSchedule = schedule
+	}
+	schedule, err = scheduler.Parse(scrapeSchedule)
+	if err != nil {
+		log.Fatal("Invalid TV_SCRAPE_SCHEDULE: ", err)
+	}
+	go scheduler.Start(scheduler.Job{
+		Name:     "torrents-scrape",
+		Schedule: schedule,
+		Run:      scraper.New(scraper.Config{}).Run,
+	})
+	everyMinute, _ := scheduler.Parse("* * * * *")
+	go scheduler.Start(scheduler.Job{
+		Name:     "downloads-sync",
+		Schedule: everyMinute,
+		Run:      download.SyncDownloads,
+	})
+	jobConcurrency := 4
+	if concurrency := os.Getenv("TV_JOB_CONCURRENCY"); concurrency != "" {
+		jobConcurrency, err = strconv.Atoi(concurrency)
+		if err != nil || jobConcurrency < 1 {
+			log.Fatal("Invalid TV_JOB_CONCURRENCY: ", concurrency)
+		}
+	}
+	go jobs.Work(jobConcurrency, time.Second)
+	serverConfig := server.Config{
+		Addr:         ":8080",
+		CertFile:     os.Getenv("TV_TLS_CERT"),
+		KeyFile:      os.Getenv("TV_TLS_KEY"),
+		RedirectAddr: os.Getenv("TV_HTTP_REDIRECT"),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+	if addr := os.Getenv("TV_LISTEN"); addr != "" {
+		serverConfig.Addr = addr
+	}
+	if serverConfig.TLS() {
+		serverConfig.HSTSMaxAge = 365 * 24 * time.Hour
+		if maxAge := os.Getenv("TV_HSTS_MAX_AGE"); maxAge != "" {
+			serverConfig.HSTSMaxAge, err = time.ParseDuration(maxAge)
+			if err != nil {
+				log.Fatal("Invalid TV_HSTS_MAX_AGE: ", maxAge)
+			}
+		}
+	}
+	log.Fatal(server.ListenAndServe(serverConfig, r))
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/torrent-viewer/backend/auth"
+	"github.com/torrent-viewer/backend/jobs"
+	"github.com/torrent-viewer/backend/router"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	r := router.NewRouter()
+	r.Use(router.FirewallMiddleware(router.FirewallConfig{
+		Guard: auth.Restrict(auth.Users(map[string]string{"admin": "password", "alice": "secret"}), "admin"),
+		Only:  adminRoutes,
+	}))
+	r.AddRoutes(jobs.Routes())
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for _, path := range []string{"/jobs/1/cancel", "/jobs/1/retry"} {
+		request, err := http.NewRequest("POST", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Username", "alice")
+		request.Header.Set("Password", "secret")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected HTTP %d for %s, got HTTP %d", http.StatusUnauthorized, path, response.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/jobs"
+	"github.com/torrent-viewer/backend/resources/show"
+)
+
+// SyncJob is the type of the jobs syncing a show with its metadata
+const SyncJob = "metadata.sync"
+
+type syncPayload struct {
+	ShowID int `json:"show_id"`
+}
+
+// SyncHandler returns the jobs.Handler syncing the show of a SyncJob with
+// the given provider.
+// Only the failures to reach the provider are retried.
+func SyncHandler(provider Provider) jobs.Handler {
+	return func(payload []byte) error {
+		var p syncPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		var s show.Show
+		if err := datastore.FetchEntity(&s, p.ShowID); err != nil {
+			if err.Status == "404" {
+				// The show was deleted since the job was queued
+				return nil
+			}
+			return err
+		}
+		if err := Sync(provider, &s, datastore.Actor{Principal: "scheduler"}); err != nil && err.Status == "502" {
+			return err
+		}
+		return nil
+	}
+}
+
+// EnqueueRefresh queues a SyncJob for every continuing show
+func EnqueueRefresh() {
+	var shows show.Shows
+	if err := datastore.FetchEntities(&shows, "continuing = ?", true); err != nil {
+		log.Printf("Could not fetch continuing shows: %s\n", err.Detail)
+		return
+	}
+	for _, s := range shows {
+		if _, err := jobs.Enqueue(SyncJob, syncPayload{ShowID: s.ID}); err != nil {
+			log.Printf("Could not queue the sync of show %d: %s\n", s.ID, err)
+		}
+	}
+}
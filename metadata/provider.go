@@ -0,0 +1,44 @@
+package metadata
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a Provider when the requested show does not exist
+var ErrNotFound = errors.New("show not found")
+
+// Show is the metadata of a show, as known by a Provider
+type Show struct {
+	ExternalID string
+	Title      string
+	Status     string
+	Seasons    []Season
+	Episodes   []Episode
+}
+
+// Season is the metadata of a season of a show
+type Season struct {
+	Number     int
+	Title      string
+	PosterPath string
+	AirYear    int
+}
+
+// Episode is the metadata of an episode of a show
+type Episode struct {
+	Season  int
+	Number  int
+	Title   string
+	Summary string
+	AirDate *time.Time
+}
+
+// Provider fetches the metadata of shows from an external source
+type Provider interface {
+	// FindShow returns the external ID of the show best matching title
+	FindShow(title string) (string, error)
+	// FetchShow returns the metadata of the show of the given external ID,
+	// including its seasons and episodes
+	FetchShow(externalID string) (*Show, error)
+}
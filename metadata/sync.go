@@ -0,0 +1,234 @@
+package metadata
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/episode"
+	"github.com/torrent-viewer/backend/resources/season"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+// Sync fetches the metadata of a show from provider, and creates or updates
+// its seasons and episodes accordingly.
+// The show is looked up by title if its external ID is not known yet.
+// The outcome of the synchronization is recorded on the show.
+func Sync(provider Provider, s *show.Show, by datastore.Actor) *herr.Error {
+	err := sync(provider, s, by)
+	if recordErr := recordSync(s, err); err == nil {
+		err = recordErr
+	}
+	return err
+}
+
+func sync(provider Provider, s *show.Show, by datastore.Actor) *herr.Error {
+	externalID := s.ExternalID
+	if externalID == "" {
+		id, err := provider.FindShow(s.Title)
+		if err != nil {
+			return providerError(err)
+		}
+		externalID = id
+	}
+	meta, err := provider.FetchShow(externalID)
+	if err != nil {
+		return providerError(err)
+	}
+	fields := []string{}
+	if s.ExternalID != meta.ExternalID {
+		s.ExternalID = meta.ExternalID
+		fields = append(fields, "ExternalID")
+	}
+	if continuing := meta.Status != "Ended"; s.Continuing != continuing {
+		s.Continuing = continuing
+		fields = append(fields, "Continuing")
+	}
+	if err := datastore.UpdateEntity(s, fields, by); err != nil {
+		return err
+	}
+	if err := syncSeasons(s, meta.Seasons, by); err != nil {
+		return err
+	}
+	return syncEpisodes(s, meta.Episodes, by)
+}
+
+// recordSync stores the outcome of the last synchronization of a show,
+// without creating a new version of it
+func recordSync(s *show.Show, failure *herr.Error) *herr.Error {
+	now := time.Now()
+	s.SyncedAt = &now
+	s.SyncError = ""
+	if failure != nil {
+		s.SyncError = failure.Detail
+	}
+	columns := map[string]interface{}{
+		"synced_at":  s.SyncedAt,
+		"sync_error": s.SyncError,
+	}
+	if err := datastore.Conn.Model(s).UpdateColumns(columns).Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	return nil
+}
+
+func syncSeasons(s *show.Show, seasons []Season, by datastore.Actor) *herr.Error {
+	var existing season.Seasons
+	if err := datastore.FetchEntities(&existing, "show_id = ?", s.ID); err != nil {
+		return err
+	}
+	numbers := make(map[int]*season.Season)
+	for _, e := range existing {
+		numbers[e.Number] = e
+	}
+	for _, meta := range seasons {
+		e, ok := numbers[meta.Number]
+		if !ok {
+			e = &season.Season{
+				ShowID: s.ID,
+				Number: meta.Number,
+			}
+		}
+		fields := []string{}
+		if e.Title != meta.Title {
+			e.Title = meta.Title
+			fields = append(fields, "Title")
+		}
+		if e.PosterPath != meta.PosterPath {
+			e.PosterPath = meta.PosterPath
+			fields = append(fields, "PosterPath")
+		}
+		if e.AirYear != meta.AirYear {
+			e.AirYear = meta.AirYear
+			fields = append(fields, "AirYear")
+		}
+		var err *herr.Error
+		if !ok {
+			err = datastore.StoreEntity(e, by)
+		} else {
+			err = datastore.UpdateEntity(e, fields, by)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncEpisodes(s *show.Show, episodes []Episode, by datastore.Actor) *herr.Error {
+	var existing episode.Episodes
+	if err := datastore.FetchEntities(&existing, "show_id = ?", s.ID); err != nil {
+		return err
+	}
+	type key struct {
+		season int
+		number int
+	}
+	numbers := make(map[key]*episode.Episode)
+	for _, e := range existing {
+		numbers[key{e.Season, e.Number}] = e
+	}
+	for _, meta := range episodes {
+		e, ok := numbers[key{meta.Season, meta.Number}]
+		if !ok {
+			e = &episode.Episode{
+				ShowID: s.ID,
+				Season: meta.Season,
+				Number: meta.Number,
+			}
+		}
+		fields := []string{}
+		if e.Title != meta.Title {
+			e.Title = meta.Title
+			fields = append(fields, "Title")
+		}
+		if e.Summary != meta.Summary {
+			e.Summary = meta.Summary
+			fields = append(fields, "Summary")
+		}
+		if sameDate(e.AirDate, meta.AirDate) == false {
+			e.AirDate = meta.AirDate
+			fields = append(fields, "AirDate")
+		}
+		var err *herr.Error
+		if !ok {
+			err = datastore.StoreEntity(e, by)
+		} else {
+			err = datastore.UpdateEntity(e, fields, by)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameDate(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+func providerError(err error) *herr.Error {
+	if err == ErrNotFound {
+		return &herr.Error{
+			ID:     "metadata-not-found",
+			Status: "404",
+			Title:  "Metadata Not Found",
+			Detail: "The show could not be found in the metadata source",
+		}
+	}
+	return &herr.Error{
+		ID:     "metadata-error",
+		Status: "502",
+		Title:  "Metadata Source Error",
+		Detail: err.Error(),
+	}
+}
+
+// Routes returns the routes used to synchronize shows with provider
+func Routes(provider Provider) router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/shows/{id:[0-9]+}/sync",
+			Handler: syncHandler(provider),
+			Method:  "POST",
+			Name:    "shows.sync",
+		},
+	}
+}
+
+// syncHandler returns the HTTP endpoint used to synchronize a Show instance by its ID
+func syncHandler(provider Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := requests.ParseID(r)
+		if err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		var s show.Show
+		if err := datastore.FetchEntity(&s, id); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		if err := Sync(provider, &s, requests.Actor(r)); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		if err := show.LoadStats(&s); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		responses.SendEntity(w, &s, http.StatusOK)
+	}
+}
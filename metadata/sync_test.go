@@ -0,0 +1,199 @@
+package metadata
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/jobs"
+	"github.com/torrent-viewer/backend/resources/episode"
+	"github.com/torrent-viewer/backend/resources/season"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/resources/torrent"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	tvmaze  *httptest.Server
+	baseURL string
+)
+
+const tvmazeDocument = `{
+	"id": 82,
+	"name": "Game of Thrones",
+	"status": "Ended",
+	"_embedded": {
+		"seasons": [
+			{"number": 1, "name": "", "premiereDate": "2011-04-17", "image": {"original": "http://example.com/s1.jpg"}}
+		],
+		"episodes": [
+			{"season": 1, "number": 1, "name": "Winter is Coming", "summary": "<p>Lord Stark...</p>", "airdate": "2011-04-17"},
+			{"season": 1, "number": 2, "name": "The Kingsroad", "summary": "", "airdate": "2011-04-24"}
+		]
+	}
+}`
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-metadata-test.db")
+	datastore.Conn.AutoMigrate(&show.Show{}, &season.Season{}, &episode.Episode{}, &torrent.Torrent{}, &jobs.Job{})
+	datastore.Register(&show.Show{}, &season.Season{}, &episode.Episode{}, &torrent.Torrent{})
+	tvmaze = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/singlesearch/shows" && r.URL.Query().Get("q") == "Game of Thrones":
+			fmt.Fprint(w, `{"id": 82, "name": "Game of Thrones"}`)
+		case r.URL.Path == "/shows/82":
+			fmt.Fprint(w, tvmazeDocument)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	r := router.NewRouter()
+	r.AddResource("shows", show.ShowResource{})
+	r.AddRoutes(Routes(NewTVMaze(tvmaze.URL)))
+	server = httptest.NewServer(r)
+	baseURL = server.URL
+	ret := m.Run()
+	datastore.Conn.DropTable(&show.Show{}, &season.Season{}, &episode.Episode{}, &torrent.Torrent{}, &jobs.Job{})
+	os.Exit(ret)
+}
+
+func postSync(t *testing.T, id int) *http.Response {
+	response, err := http.Post(fmt.Sprintf("%s/shows/%d/sync", baseURL, id), "application/vnd.api+json", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestSync(t *testing.T) {
+	s := show.Show{Title: "Game of Thrones", Year: 2011}
+	if err := datastore.StoreEntity(&s, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		response := postSync(t, s.ID)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+		}
+		var payload struct {
+			Data struct {
+				Meta struct {
+					EpisodesCount int `json:"episodes_count"`
+				} `json:"meta"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Data.Meta.EpisodesCount != 2 {
+			t.Errorf("Expected 2 episodes in the show meta, got %d", payload.Data.Meta.EpisodesCount)
+		}
+	}
+	if err := datastore.FetchEntity(&s, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.ExternalID != "82" {
+		t.Errorf("Expected external ID %q, got %q", "82", s.ExternalID)
+	}
+	var seasons season.Seasons
+	if err := datastore.FetchEntities(&seasons, "show_id = ?", s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(seasons) != 1 || seasons[0].AirYear != 2011 || seasons[0].PosterPath != "http://example.com/s1.jpg" {
+		t.Errorf("Unexpected seasons %+v", seasons)
+	}
+	var episodes episode.Episodes
+	if err := datastore.FetchEntities(&episodes, "show_id = ?", s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(episodes) != 2 {
+		t.Fatalf("Expected 2 episodes, got %d", len(episodes))
+	}
+	if episodes[0].Title != "Winter is Coming" || episodes[0].AirDate == nil || episodes[0].Version != 1 {
+		t.Errorf("Unexpected episode %+v", episodes[0])
+	}
+}
+
+func TestSyncNotFound(t *testing.T) {
+	s := show.Show{Title: "Unknown Show", Year: 2000}
+	if err := datastore.StoreEntity(&s, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	response := postSync(t, s.ID)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+}
+
+func TestSyncJob(t *testing.T) {
+	s := show.Show{Title: "Game of Thrones", Year: 2011, Continuing: true}
+	ended := show.Show{Title: "Unknown Show", Year: 2000}
+	for _, in := range []*show.Show{&s, &ended} {
+		if err := datastore.StoreEntity(in, datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	EnqueueRefresh()
+	var queued jobs.Jobs
+	if err := datastore.FetchEntities(&queued, "type = ?", SyncJob); err != nil {
+		t.Fatal(err)
+	}
+	payload := fmt.Sprintf(`{"show_id":%d}`, s.ID)
+	var job *jobs.Job
+	for _, j := range queued {
+		if j.Payload == payload {
+			job = j
+		}
+		if j.Payload == fmt.Sprintf(`{"show_id":%d}`, ended.ID) {
+			t.Errorf("Expected a show not continuing not to be synced")
+		}
+	}
+	if job == nil {
+		t.Fatalf("Expected a sync job for show %d, got %v", s.ID, queued)
+	}
+	handler := SyncHandler(NewTVMaze(tvmaze.URL))
+	if err := handler([]byte(job.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.FetchEntity(&s, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.SyncedAt == nil || s.SyncError != "" {
+		t.Errorf("Expected a successful sync, got %v %q", s.SyncedAt, s.SyncError)
+	}
+	// The show has ended according to the metadata source
+	if s.Continuing {
+		t.Error("Expected the show not to be continuing anymore")
+	}
+	if s.Meta()["synced_at"] == nil {
+		t.Error("Expected the sync status in the show meta")
+	}
+	// Shows deleted since the job was queued are skipped
+	if err := handler([]byte(`{"show_id":0}`)); err != nil {
+		t.Errorf("Expected a missing show to be skipped, got %v", err)
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	s := show.Show{Title: "Unknown Show", Year: 2000}
+	if err := datastore.StoreEntity(&s, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	postSync(t, s.ID)
+	if err := datastore.FetchEntity(&s, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.SyncedAt == nil || s.SyncError == "" {
+		t.Errorf("Expected the sync error to be recorded, got %v %q", s.SyncedAt, s.SyncError)
+	}
+}
@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TVMaze is a Provider for TVMaze-compatible APIs
+type TVMaze struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+type tvmazeShow struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Embedded struct {
+		Seasons []struct {
+			Number       int    `json:"number"`
+			Name         string `json:"name"`
+			PremiereDate string `json:"premiereDate"`
+			Image        *struct {
+				Original string `json:"original"`
+			} `json:"image"`
+		} `json:"seasons"`
+		Episodes []struct {
+			Season  int    `json:"season"`
+			Number  int    `json:"number"`
+			Name    string `json:"name"`
+			Summary string `json:"summary"`
+			Airdate string `json:"airdate"`
+		} `json:"episodes"`
+	} `json:"_embedded"`
+}
+
+// NewTVMaze creates a TVMaze provider querying the API at baseURL
+func NewTVMaze(baseURL string) *TVMaze {
+	return &TVMaze{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (t *TVMaze) get(path string, out interface{}) error {
+	response, err := t.Client.Get(t.BaseURL + path)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected HTTP %d", path, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// FindShow returns the TVMaze ID of the show best matching title
+func (t *TVMaze) FindShow(title string) (string, error) {
+	var show tvmazeShow
+	if err := t.get(fmt.Sprintf("/singlesearch/shows?q=%s", url.QueryEscape(title)), &show); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(show.ID), nil
+}
+
+// FetchShow returns the metadata of the show of the given TVMaze ID
+func (t *TVMaze) FetchShow(externalID string) (*Show, error) {
+	var show tvmazeShow
+	path := fmt.Sprintf("/shows/%s?embed[]=seasons&embed[]=episodes", url.PathEscape(externalID))
+	if err := t.get(path, &show); err != nil {
+		return nil, err
+	}
+	out := &Show{
+		ExternalID: strconv.Itoa(show.ID),
+		Title:      show.Name,
+		Status:     show.Status,
+	}
+	for _, s := range show.Embedded.Seasons {
+		season := Season{
+			Number: s.Number,
+			Title:  s.Name,
+		}
+		if s.Image != nil {
+			season.PosterPath = s.Image.Original
+		}
+		if premiere := parseDate(s.PremiereDate); premiere != nil {
+			season.AirYear = premiere.Year()
+		}
+		out.Seasons = append(out.Seasons, season)
+	}
+	for _, e := range show.Embedded.Episodes {
+		out.Episodes = append(out.Episodes, Episode{
+			Season:  e.Season,
+			Number:  e.Number,
+			Title:   e.Name,
+			Summary: e.Summary,
+			AirDate: parseDate(e.Airdate),
+		})
+	}
+	return out, nil
+}
+
+// parseDate parses a YYYY-MM-DD date, returning nil if it is empty or invalid
+func parseDate(value string) *time.Time {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil
+	}
+	return &date
+}
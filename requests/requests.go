@@ -1,14 +1,21 @@
 package requests
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/shwoodard/jsonapi"
 	"github.com/torrent-viewer/backend/datastore"
 	"github.com/torrent-viewer/backend/router"
 	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/responses"
 )
 
 var (
@@ -37,9 +44,9 @@ type Pagination struct {
 	Limit int
 }
 
-func Paginate(model interface{}, r *http.Request) (Pagination, *herr.Error) {
+func Paginate(model interface{}, r *http.Request, scopes ...datastore.Scope) (Pagination, *herr.Error) {
 	var total int
-	if err := datastore.CountEntities(model, &total, nil); err != nil {
+	if err := datastore.CountEntities(model, &total, scopes...); err != nil {
 		return Pagination{}, err
 	}
 	queries := r.URL.Query()
@@ -78,6 +85,231 @@ func Paginate(model interface{}, r *http.Request) (Pagination, *herr.Error) {
 	}, nil
 }
 
+// ParseTrashed parses the filter[trashed] query parameter.
+// "with" includes soft-deleted entities and "only" restricts to them,
+// they are excluded otherwise.
+func ParseTrashed(r *http.Request) (datastore.Scope, *herr.Error) {
+	switch r.URL.Query().Get("filter[trashed]") {
+	case "":
+		return datastore.WithoutTrashed, nil
+	case "with":
+		return datastore.WithTrashed, nil
+	case "only":
+		return datastore.OnlyTrashed, nil
+	}
+	return nil, &herr.Error{
+		ID:     "invalid-parameter",
+		Status: "400",
+		Title:  "Invalid query parameter",
+		Detail: "filter[trashed] must be either \"with\" or \"only\"",
+		Source: herr.ErrorSource{
+			Parameter: "filter[trashed]",
+		},
+	}
+}
+
+// ParseSort parses the sort query parameter, a comma-separated list of
+// attributes of model, each of them prefixed by "-" for a descending order.
+// Entities are sorted by ID by default.
+func ParseSort(r *http.Request, model interface{}) (datastore.Scope, *herr.Error) {
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		return datastore.Order("id"), nil
+	}
+	columns := datastore.AttributeColumns(model)
+	orders := []string{}
+	for _, attr := range strings.Split(sort, ",") {
+		direction := "asc"
+		if strings.HasPrefix(attr, "-") {
+			direction = "desc"
+			attr = strings.TrimPrefix(attr, "-")
+		}
+		column, ok := columns[attr]
+		if !ok {
+			return nil, &herr.Error{
+				ID:     "invalid-parameter",
+				Status: "400",
+				Title:  "Invalid query parameter",
+				Detail: fmt.Sprintf("Cannot sort by %s", attr),
+				Source: herr.ErrorSource{
+					Parameter: "sort",
+				},
+			}
+		}
+		orders = append(orders, fmt.Sprintf("%s %s", column, direction))
+	}
+	return datastore.Order(strings.Join(orders, ", ")), nil
+}
+
+// ParseInclude parses the include query parameter, a comma-separated list of
+// the relationships whose resources are included in the response.
+// Only the given relationships can be included.
+func ParseInclude(r *http.Request, allowed ...string) ([]string, *herr.Error) {
+	include := r.URL.Query().Get("include")
+	if include == "" {
+		return []string{}, nil
+	}
+	includes := []string{}
+	for _, name := range strings.Split(include, ",") {
+		found := false
+		for _, a := range allowed {
+			found = found || a == name
+		}
+		if !found {
+			return nil, &herr.Error{
+				ID:     "invalid-parameter",
+				Status: "400",
+				Title:  "Invalid query parameter",
+				Detail: fmt.Sprintf("Cannot include %s", name),
+				Source: herr.ErrorSource{
+					Parameter: "include",
+				},
+			}
+		}
+		includes = append(includes, name)
+	}
+	return includes, nil
+}
+
+// ParseFilters parses the filter[<name>] query parameters,
+// each of them being a comma-separated list of accepted values.
+// columns maps the name of each allowed filter to the column it matches,
+// other filters are ignored.
+func ParseFilters(r *http.Request, columns map[string]string) []datastore.Scope {
+	queries := r.URL.Query()
+	scopes := []datastore.Scope{}
+	for name, column := range columns {
+		if values, ok := queries[fmt.Sprintf("filter[%s]", name)]; ok {
+			matches := []string{}
+			for _, value := range values {
+				matches = append(matches, strings.Split(value, ",")...)
+			}
+			scopes = append(scopes, datastore.Where(fmt.Sprintf("%s IN (?)", column), matches))
+		}
+	}
+	return scopes
+}
+
+// Actor returns the datastore Actor responsible for the current Request
+func Actor(r *http.Request) datastore.Actor {
+	return datastore.Actor{
+		Principal: router.Principal(r),
+		RequestID: router.RequestID(r),
+	}
+}
+
+// CheckIfMatch ensures that the If-Match header, if any,
+// matches the current version of entity.
+// The meta information fingerprinted in the ETag is not part of the state
+// of the entity, so that only its version is compared.
+func CheckIfMatch(r *http.Request, entity interface{}) *herr.Error {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchETag(header, responses.ETag(entity), false, responses.VersionTag) {
+		return nil
+	}
+	return &herr.PreconditionFailedError
+}
+
+// NotModified reports whether the If-None-Match header
+// matches the current ETag of entity, whose meta information must be loaded.
+func NotModified(r *http.Request, entity interface{}) bool {
+	header := r.Header.Get("If-None-Match")
+	return header != "" && matchETag(header, responses.ETag(entity), true, nil)
+}
+
+// matchETag reports whether one of the entity tags listed in header matches etag.
+// Weak entity tags only match if weak comparison is allowed.
+// The tags of compressed representations match the tag of the uncompressed one.
+// The tags are transformed by the given function before being compared, if any.
+func matchETag(header string, etag string, weak bool, transform func(string) string) bool {
+	if etag == "" {
+		return false
+	}
+	if transform != nil {
+		etag = transform(etag)
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		candidate = router.DecodedETag(candidate)
+		if transform != nil {
+			candidate = transform(candidate)
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ReceiveLinkage decodes the relationship linkage of the request body.
+// The linkage must be an array for a to-many relationship, and either an
+// object or null for a to-one relationship.
+// It returns the IDs of the linked resources, which must be of the given type.
+func ReceiveLinkage(r *http.Request, resourceType string, toMany bool) ([]int, *herr.Error) {
+	var document struct {
+		Data json.RawMessage `json:"data"`
+	}
+	malformated := &herr.Error{
+		ID:     "malformated-input",
+		Status: "400",
+		Title:  "Malformated input",
+		Source: herr.ErrorSource{
+			Pointer: "/data",
+		},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&document); err != nil {
+		malformated.Detail = err.Error()
+		return nil, malformated
+	}
+	identifiers := []responses.ResourceIdentifier{}
+	if toMany {
+		if err := json.Unmarshal(document.Data, &identifiers); err != nil {
+			malformated.Detail = "The linkage of a to-many relationship must be an array"
+			return nil, malformated
+		}
+	} else if document.Data != nil && string(document.Data) != "null" {
+		var identifier responses.ResourceIdentifier
+		if err := json.Unmarshal(document.Data, &identifier); err != nil {
+			malformated.Detail = "The linkage of a to-one relationship must be an object or null"
+			return nil, malformated
+		}
+		identifiers = append(identifiers, identifier)
+	} else if document.Data == nil {
+		malformated.Detail = "The document does not contain linkage"
+		return nil, malformated
+	}
+	ids := make([]int, len(identifiers), len(identifiers))
+	for i, identifier := range identifiers {
+		if identifier.Type != resourceType {
+			return nil, &herr.Error{
+				ID:     "unmatching-types",
+				Status: "409",
+				Title:  "Types do not match",
+				Detail: fmt.Sprintf("The linkage must refer to %s resources", resourceType),
+				Source: herr.ErrorSource{
+					Pointer: "/data",
+				},
+			}
+		}
+		id, err := strconv.Atoi(identifier.ID)
+		if err != nil {
+			malformated.Detail = err.Error()
+			return nil, malformated
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// ReceiveEntity decodes the JSON API document of the request body into entity
 func ReceiveEntity(r *http.Request, entity interface{}) *herr.Error {
 	if err := jsonapi.UnmarshalPayload(r.Body, entity); err != nil {
 		return &herr.Error{
@@ -87,16 +319,182 @@ func ReceiveEntity(r *http.Request, entity interface{}) *herr.Error {
 			Detail: err.Error(),
 		}
 	}
-	if result, err := govalidator.ValidateStruct(entity); err != nil || result != true {
-		e := herr.Error{
+	return nil
+}
+
+// ValidateEntity checks entity against its validation tags.
+// It returns one Error per failing attribute, or nil if entity is valid.
+func ValidateEntity(entity interface{}) herr.Errors {
+	return validate(entity, nil)
+}
+
+// ValidateFields checks the given fields of entity against their validation
+// tags, the failures of other fields are ignored.
+func ValidateFields(entity interface{}, fields []string) herr.Errors {
+	if len(fields) == 0 {
+		return nil
+	}
+	return validate(entity, fields)
+}
+
+// validate checks entity and reports the failures of the given fields,
+// or of every field if fields is nil.
+func validate(entity interface{}, fields []string) herr.Errors {
+	result, err := govalidator.ValidateStruct(entity)
+	if err == nil && result == true {
+		return nil
+	}
+	errs := herr.Errors{}
+	if err != nil {
+		errs = appendValidationErrors(errs, entity, err, fields)
+	}
+	if len(errs) == 0 && fields == nil {
+		errs = append(errs, herr.Error{
 			ID:     "validation-error",
-			Status: "400",
+			Status: "422",
 			Title:  "Validation Error",
+		})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func appendValidationErrors(errs herr.Errors, entity interface{}, err error, fields []string) herr.Errors {
+	switch e := err.(type) {
+	case govalidator.Errors:
+		for _, nested := range e {
+			errs = appendValidationErrors(errs, entity, nested, fields)
+		}
+	case govalidator.Error:
+		if fields != nil && containsString(fields, e.Name) == false {
+			return errs
 		}
+		attr := attributeName(entity, e.Name)
+		code := e.Validator
+		if code == "" {
+			code = "invalid"
+		}
+		detail := e.Err.Error()
+		if e.CustomErrorMessageExists == false {
+			if code == "required" {
+				detail = fmt.Sprintf("The %s attribute is required.", attr)
+			} else {
+				detail = fmt.Sprintf("The %s attribute does not validate as %s.", attr, code)
+			}
+		}
+		errs = append(errs, herr.Error{
+			ID:     "validation-error",
+			Status: "422",
+			Code:   code,
+			Title:  "Validation Error",
+			Detail: detail,
+			Source: herr.ErrorSource{
+				Pointer: fmt.Sprintf("/data/attributes/%s", attr),
+			},
+		})
+	default:
+		errs = append(errs, herr.Error{
+			ID:     "validation-error",
+			Status: "422",
+			Title:  "Validation Error",
+			Detail: err.Error(),
+		})
+	}
+	return errs
+}
+
+// attributeName returns the JSON API attribute name of the given struct field.
+// The field name is returned as is if it is not a JSON API attribute.
+func attributeName(entity interface{}, field string) string {
+	t := reflect.TypeOf(entity)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if f, ok := t.FieldByName(field); ok {
+		args := strings.Split(f.Tag.Get("jsonapi"), ",")
+		if len(args) >= 2 && args[0] == "attr" {
+			return args[1]
+		}
+	}
+	return field
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyAttributes are the attributes maintained by the datastore, which
+// clients cannot change.
+var readOnlyAttributes = []string{"created_at", "updated_at"}
+
+// ReceivePatch decodes the JSON API document of the request body into entity,
+// which should have been fetched from the datastore beforehand.
+// Attributes explicitly set to null are reset to their zero value.
+// Read-only attributes are ignored, so that clients can send back a whole
+// resource object.
+// It returns the names of the fields present in the document.
+func ReceivePatch(r *http.Request, entity interface{}) ([]string, *herr.Error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, &herr.Error{
+			ID:     "malformated-input",
+			Status: "400",
+			Title:  "Malformated input",
+			Detail: err.Error(),
+		}
+	}
+	var document struct {
+		Data *struct {
+			Attributes map[string]json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil || document.Data == nil {
+		detail := "The document does not contain primary data"
 		if err != nil {
-			e.Detail = err.Error()
+			detail = err.Error()
+		}
+		return nil, &herr.Error{
+			ID:     "malformated-input",
+			Status: "400",
+			Title:  "Malformated input",
+			Detail: detail,
 		}
-		return &e
 	}
-	return nil
-}
\ No newline at end of file
+	v := reflect.Indirect(reflect.ValueOf(entity))
+	stored := reflect.New(v.Type()).Elem()
+	stored.Set(v)
+	if err := jsonapi.UnmarshalPayload(bytes.NewReader(body), entity); err != nil {
+		return nil, &herr.Error{
+			ID:     "malformated-input",
+			Status: "400",
+			Title:  "Malformated input",
+			Detail: err.Error(),
+		}
+	}
+	fields := []string{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		args := strings.Split(field.Tag.Get("jsonapi"), ",")
+		if len(args) < 2 || args[0] != "attr" {
+			continue
+		}
+		if containsString(readOnlyAttributes, args[1]) {
+			v.Field(i).Set(stored.Field(i))
+			continue
+		}
+		if raw, ok := document.Data.Attributes[args[1]]; ok {
+			if string(raw) == "null" {
+				v.Field(i).Set(reflect.Zero(field.Type))
+			}
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+type Event struct {
+	ID           int       `jsonapi:"primary,audit-events" gorm:"primary_key"`
+	CreatedAt    time.Time `jsonapi:"attr,created_at"`
+	Actor        string    `jsonapi:"attr,actor" sql:"index"`
+	Action       string    `jsonapi:"attr,action"`
+	ResourceType string    `jsonapi:"attr,resource_type" sql:"index"`
+	ResourceID   int       `jsonapi:"attr,resource_id" sql:"index"`
+	Changes      string    `jsonapi:"attr,changes" sql:"type:text"`
+	RequestID    string    `jsonapi:"attr,request_id"`
+}
+
+type Events []*Event
+
+type AuditResource struct{}
+
+func (Event) TableName() string {
+	return "audit_events"
+}
+
+func (e Event) GetID() int {
+	return e.ID
+}
+
+// Change is the value of an attribute before and after a mutation
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// audited are the actions of the mutations recorded by the audit log.
+// Changes of the state of entities, such as the progress of downloads, are
+// not made by anyone and are left out.
+var audited = map[string]bool{
+	"create":  true,
+	"update":  true,
+	"delete":  true,
+	"restore": true,
+	"purge":   true,
+}
+
+// Record stores an Event describing the given mutation.
+// It is meant to be registered with datastore.OnMutation.
+func Record(m datastore.Mutation) {
+	if !audited[m.Action] {
+		return
+	}
+	changes := make(map[string]Change)
+	for name, value := range m.After {
+		if previous, ok := m.Before[name]; !ok || !reflect.DeepEqual(previous, value) {
+			changes[name] = Change{From: m.Before[name], To: value}
+		}
+	}
+	for name, previous := range m.Before {
+		if _, ok := m.After[name]; !ok {
+			changes[name] = Change{From: previous}
+		}
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		log.Println("Could not encode audit changes:", err)
+	}
+	event := Event{
+		CreatedAt:    m.Time,
+		Actor:        m.Actor.Principal,
+		Action:       m.Action,
+		ResourceType: m.Type,
+		ResourceID:   m.ID,
+		Changes:      string(encoded),
+		RequestID:    m.Actor.RequestID,
+	}
+	if err := datastore.Conn.Create(&event).Error; err != nil {
+		log.Println("Could not record audit event:", err)
+	}
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/responses"
+)
+
+var filters = map[string]string{
+	"actor":         "actor",
+	"action":        "action",
+	"resource_type": "resource_type",
+	"resource_id":   "resource_id",
+	"request_id":    "request_id",
+}
+
+// AuditList is the HTTP endpoint used to list Event instances, newest first
+func (AuditResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Events
+	scopes := requests.ParseFilters(r, filters)
+	page, err := requests.Paginate(&Event{}, r, scopes...)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	scopes = append(scopes, datastore.Order("id desc"))
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, scopes...); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// AuditView is the HTTP endpoint used to show an Event instance by ID
+func (AuditResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var event Event
+	if err := datastore.FetchEntity(&event, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &event, http.StatusOK)
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+)
+
+type movie struct {
+	ID        int        `jsonapi:"primary,movies" gorm:"primary_key"`
+	Title     string     `jsonapi:"attr,title"`
+	DeletedAt *time.Time `jsonapi:"" sql:"index"`
+}
+
+func (m movie) GetID() int {
+	return m.ID
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-audit-test.db")
+	datastore.Conn.AutoMigrate(&Event{}, &movie{})
+	datastore.OnMutation(Record)
+	r := router.NewRouter()
+	r.AddResource("audit-events", AuditResource{})
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/audit-events", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&Event{}, &movie{})
+	os.Exit(ret)
+}
+
+func TestAuditList(t *testing.T) {
+	by := datastore.Actor{Principal: "admin", RequestID: "abc"}
+	m := movie{Title: "Alien"}
+	if err := datastore.StoreEntity(&m, by); err != nil {
+		t.Fatal(err)
+	}
+	m.Title = "Aliens"
+	if err := datastore.UpdateEntity(&m, []string{"Title"}, by); err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.Get(fmt.Sprintf("%s?filter[resource_type]=movies&filter[action]=update", baseURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var payload struct {
+		Data []struct {
+			Attributes struct {
+				Actor     string `json:"actor"`
+				Changes   string `json:"changes"`
+				RequestID string `json:"request_id"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Data) != 1 {
+		t.Fatalf("Expected 1 audit event, got %d", len(payload.Data))
+	}
+	event := payload.Data[0].Attributes
+	if event.Actor != "admin" || event.RequestID != "abc" {
+		t.Errorf("Expected event by admin in request abc, got %s in request %s", event.Actor, event.RequestID)
+	}
+	if event.Changes != `{"title":{"from":"Alien","to":"Aliens"}}` {
+		t.Errorf("Unexpected changes %s", event.Changes)
+	}
+}
+
+func TestAuditPurgeTrashed(t *testing.T) {
+	m := movie{Title: "Prometheus"}
+	if err := datastore.StoreEntity(&m, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.DeleteEntity(&m, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	count, err := datastore.PurgeTrashed(&movie{}, time.Now().Add(time.Minute), datastore.Actor{Principal: "scheduler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 purged movie, got %d", count)
+	}
+	var events Events
+	if err := datastore.FetchEntities(&events, "action = ? AND resource_id = ?", "purge", m.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Actor != "scheduler" {
+		t.Fatalf("Expected the purge to be recorded, got %v", events)
+	}
+	if events[0].Changes != `{"title":{"from":"Prometheus","to":null}}` {
+		t.Errorf("Unexpected changes %s", events[0].Changes)
+	}
+}
@@ -0,0 +1,198 @@
+package base
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var forbiddenToOneError = herr.Error{
+	ID:     "forbidden",
+	Status: "403",
+	Title:  "Forbidden",
+	Detail: "Links can only be added to or removed from to-many relationships",
+}
+
+// fetchRelationship fetches the entity identified in the URL into model,
+// and returns its relationship named in the URL.
+func fetchRelationship(r *http.Request, model datastore.Identifiable) (datastore.Relationship, *herr.Error) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		return datastore.Relationship{}, err
+	}
+	if err := datastore.FetchEntity(model, id); err != nil {
+		return datastore.Relationship{}, err
+	}
+	rel, ok := datastore.FindRelationship(model, router.Vars(r)["relationship"])
+	if !ok {
+		return rel, &herr.Error{
+			ID:     "not-found",
+			Status: "404",
+			Title:  "Not Found",
+			Detail: "The requested relationship does not exist.",
+		}
+	}
+	return rel, nil
+}
+
+// ViewRelationship sends the linkage of a relationship of an entity.
+// model is a pointer to an empty entity of the resource.
+func ViewRelationship(w http.ResponseWriter, r *http.Request, model datastore.Identifiable) {
+	rel, err := fetchRelationship(r, model)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	ids, err := datastore.FetchLinkage(model, rel)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendLinkage(w, rel.Type, ids, rel.ToMany)
+}
+
+// UpdateRelationship replaces the linkage of a relationship of an entity.
+// model is a pointer to an empty entity of the resource.
+func UpdateRelationship(w http.ResponseWriter, r *http.Request, model datastore.Identifiable) {
+	rel, err := fetchRelationship(r, model)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	ids, err := requests.ReceiveLinkage(r, rel.Type, rel.ToMany)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if rel.ToMany {
+		err = datastore.ReplaceToMany(model, rel, ids, requests.Actor(r))
+	} else if len(ids) == 0 {
+		err = datastore.SetToOne(model, rel, 0, requests.Actor(r))
+	} else {
+		err = datastore.SetToOne(model, rel, ids[0], requests.Actor(r))
+	}
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// AddRelationship adds links to a to-many relationship of an entity.
+// model is a pointer to an empty entity of the resource.
+func AddRelationship(w http.ResponseWriter, r *http.Request, model datastore.Identifiable) {
+	rel, err := fetchRelationship(r, model)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if rel.ToMany == false {
+		responses.SendError(w, forbiddenToOneError)
+		return
+	}
+	ids, err := requests.ReceiveLinkage(r, rel.Type, true)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.AddToMany(model, rel, ids, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// RemoveRelationship removes links from a to-many relationship of an entity.
+// model is a pointer to an empty entity of the resource.
+func RemoveRelationship(w http.ResponseWriter, r *http.Request, model datastore.Identifiable) {
+	rel, err := fetchRelationship(r, model)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if rel.ToMany == false {
+		responses.SendError(w, forbiddenToOneError)
+		return
+	}
+	ids, err := requests.ReceiveLinkage(r, rel.Type, true)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.RemoveToMany(model, rel, ids, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// ViewRelated sends the resources related to an entity through one of its
+// relationships. The related resources of a to-many relationship can be
+// filtered, sorted and paginated as top-level resources.
+// model is a pointer to an empty entity of the resource.
+func ViewRelated(w http.ResponseWriter, r *http.Request, model datastore.Identifiable) {
+	rel, err := fetchRelationship(r, model)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	related := datastore.NewEntity(rel.Type)
+	if related == nil {
+		responses.SendError(w, herr.Error{
+			ID:     "unknown-type",
+			Status: "500",
+			Title:  "Unknown resource type",
+		})
+		return
+	}
+	if rel.ToMany == false {
+		ids, err := datastore.FetchLinkage(model, rel)
+		if err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		if len(ids) == 0 {
+			responses.SendLinkage(w, rel.Type, ids, false)
+			return
+		}
+		if err := datastore.FetchEntity(related, ids[0]); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		responses.SendEntity(w, related, http.StatusOK)
+		return
+	}
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, related)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	linked := datastore.Where(fmt.Sprintf("%s = ?", gorm.ToDBName(rel.ForeignKey)), model.GetID())
+	page, err := requests.Paginate(related, r, trashed, linked)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	entries := reflect.New(reflect.SliceOf(reflect.TypeOf(related)))
+	if err := datastore.FetchPagedEntities(entries.Interface(), page.Limit, page.Offset, trashed, linked, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, entries.Elem().Len(), entries.Elem().Len())
+	for i := range serialized {
+		serialized[i] = entries.Elem().Index(i).Interface()
+	}
+	responses.SendEntities(w, serialized)
+}
@@ -0,0 +1,35 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Token authenticates a user subscribing to their calendar feed,
+// as calendar applications cannot use the firewall credentials
+type Token struct {
+	ID        int       `jsonapi:"primary,calendar-tokens" gorm:"primary_key"`
+	CreatedAt time.Time `jsonapi:"attr,created_at"`
+	Principal string    `jsonapi:"attr,principal" sql:"unique_index"`
+	Token     string    `jsonapi:"attr,token" sql:"unique_index"`
+}
+
+type Tokens []*Token
+
+func (Token) TableName() string {
+	return "calendar_tokens"
+}
+
+func (t Token) GetID() int {
+	return t.ID
+}
+
+// generateToken returns a new random token
+func generateToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,268 @@
+package calendar
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/resources/episode"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+// maxEpisodes is the maximum number of episodes of a calendar
+const maxEpisodes = 1000
+
+// maxRange is the longest period covered by a calendar
+const maxRange = 366 * 24 * time.Hour
+
+// Routes returns the routes of the calendar of upcoming episodes.
+// /calendar and /me/calendar-token must be protected by a Firewall,
+// /calendar.ics is authenticated by the token given as a query parameter.
+func Routes() router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/calendar",
+			Handler: ViewCalendar,
+			Method:  "GET",
+			Name:    "calendar.view",
+		},
+		router.Route{
+			Path:    "/calendar.ics",
+			Handler: ExportCalendar,
+			Method:  "GET",
+			Name:    "calendar.export",
+		},
+		router.Route{
+			Path:    "/me/calendar-token",
+			Handler: ViewToken,
+			Method:  "GET",
+			Name:    "me.calendar-token.view",
+		},
+		router.Route{
+			Path:    "/me/calendar-token",
+			Handler: RenewToken,
+			Method:  "POST",
+			Name:    "me.calendar-token.renew",
+		},
+	}
+}
+
+// parseDate parses the query parameter name as a YYYY-MM-DD date,
+// or returns fallback if it is not given
+func parseDate(r *http.Request, name string, fallback time.Time) (time.Time, *herr.Error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return date, &herr.Error{
+			ID:     "invalid-parameter",
+			Status: "400",
+			Title:  "Invalid query parameter",
+			Detail: fmt.Sprintf("%s must be a date formatted as YYYY-MM-DD", name),
+			Source: herr.ErrorSource{
+				Parameter: name,
+			},
+		}
+	}
+	return date, nil
+}
+
+// parseRange parses the from and to query parameters. By default, the range
+// starts at fromOffset days from today and lasts for length days.
+func parseRange(r *http.Request, fromOffset int, length int) (time.Time, time.Time, *herr.Error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, err := parseDate(r, "from", today.AddDate(0, 0, fromOffset))
+	if err != nil {
+		return from, from, err
+	}
+	to, err := parseDate(r, "to", from.AddDate(0, 0, length))
+	if err != nil {
+		return from, to, err
+	}
+	if to.Before(from) || to.Sub(from) > maxRange {
+		return from, to, &herr.Error{
+			ID:     "invalid-parameter",
+			Status: "400",
+			Title:  "Invalid query parameter",
+			Detail: "to must be after from, and the range must not exceed a year",
+			Source: herr.ErrorSource{
+				Parameter: "to",
+			},
+		}
+	}
+	return from, to, nil
+}
+
+// fetchEpisodes returns the episodes of the shows on the watchlist of
+// principal airing between from and to, both included
+func fetchEpisodes(principal string, from time.Time, to time.Time) (episode.Episodes, *herr.Error) {
+	var entries episode.Episodes
+	err := datastore.FetchPagedEntities(&entries, maxEpisodes, 0,
+		datastore.Where("show_id IN (SELECT show_id FROM watchlist_entries WHERE principal = ?)", principal),
+		datastore.Where("air_date >= ? AND air_date < ?", from, to.AddDate(0, 0, 1)),
+		datastore.Order("air_date, season, number"),
+	)
+	return entries, err
+}
+
+// ViewCalendar is the HTTP endpoint used to list the Episodes of the watchlist of the current user airing in a range of dates
+func ViewCalendar(w http.ResponseWriter, r *http.Request) {
+	principal := router.Principal(r)
+	if principal == "" {
+		responses.SendError(w, herr.UnauthorizedError)
+		return
+	}
+	from, to, err := parseRange(r, 0, 7)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	entries, err := fetchEpisodes(principal, from, to)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// ExportCalendar is the HTTP endpoint used to subscribe to the calendar of a user as an iCalendar feed
+func ExportCalendar(w http.ResponseWriter, r *http.Request) {
+	var tokens Tokens
+	if value := r.URL.Query().Get("token"); value != "" {
+		if err := datastore.FetchEntities(&tokens, "token = ?", value); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+	}
+	if len(tokens) == 0 {
+		responses.SendError(w, herr.UnauthorizedError)
+		return
+	}
+	// Past episodes are kept for a while, so that they do not vanish
+	// from the calendar as soon as they aired
+	from, to, err := parseRange(r, -30, 120)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	entries, err := fetchEpisodes(tokens[0].Principal, from, to)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	titles := make(map[int]string)
+	ids := []int{}
+	for _, e := range entries {
+		ids = append(ids, e.ShowID)
+	}
+	if len(ids) > 0 {
+		var shows show.Shows
+		if err := datastore.FetchEntities(&shows, "id IN (?)", ids); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		for _, s := range shows {
+			titles[s.ID] = s.Title
+		}
+	}
+	events := make([]responses.CalendarEvent, len(entries), len(entries))
+	for i, e := range entries {
+		summary := fmt.Sprintf("%s S%02dE%02d", titles[e.ShowID], e.Season, e.Number)
+		if e.Title != "" {
+			summary = fmt.Sprintf("%s - %s", summary, e.Title)
+		}
+		events[i] = responses.CalendarEvent{
+			UID:         fmt.Sprintf("episode-%d@torrent-viewer", e.ID),
+			Date:        *e.AirDate,
+			Summary:     summary,
+			Description: e.Summary,
+			Updated:     e.UpdatedAt,
+		}
+	}
+	responses.SendCalendar(w, "Upcoming episodes", events)
+}
+
+// ViewToken is the HTTP endpoint used to show the calendar token of the current user
+func ViewToken(w http.ResponseWriter, r *http.Request) {
+	principal := router.Principal(r)
+	if principal == "" {
+		responses.SendError(w, herr.UnauthorizedError)
+		return
+	}
+	var tokens Tokens
+	if err := datastore.FetchEntities(&tokens, "principal = ?", principal); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if len(tokens) == 0 {
+		responses.SendError(w, herr.Error{
+			ID:     "not-found",
+			Status: "404",
+			Title:  "Not Found",
+			Detail: "No calendar token was created yet.",
+		})
+		return
+	}
+	responses.SendEntity(w, tokens[0], http.StatusOK)
+}
+
+// RenewToken is the HTTP endpoint used to create a new calendar token for the current user, revoking the previous one
+func RenewToken(w http.ResponseWriter, r *http.Request) {
+	principal := router.Principal(r)
+	if principal == "" {
+		responses.SendError(w, herr.UnauthorizedError)
+		return
+	}
+	value, generateErr := generateToken()
+	if generateErr != nil {
+		log.Println("Could not generate calendar token:", generateErr)
+		responses.SendError(w, herr.Error{
+			ID:     "token-error",
+			Status: "500",
+			Title:  "Token Error",
+			Detail: "The token could not be generated",
+		})
+		return
+	}
+	token := Token{
+		Principal: principal,
+		Token:     value,
+	}
+	// Tokens are secrets, they are kept out of the audit log
+	tx := datastore.Conn.Begin()
+	if err := tx.Where("principal = ?", principal).Delete(&Token{}).Error; err != nil {
+		tx.Rollback()
+		responses.SendError(w, databaseError(err))
+		return
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		tx.Rollback()
+		responses.SendError(w, databaseError(err))
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		responses.SendError(w, databaseError(err))
+		return
+	}
+	responses.SendEntity(w, &token, http.StatusCreated)
+}
+
+func databaseError(err error) herr.Error {
+	return herr.Error{
+		ID:     "database-error",
+		Status: "500",
+		Title:  "Database Error",
+		Detail: err.Error(),
+	}
+}
@@ -0,0 +1,177 @@
+package calendar
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/episode"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/resources/watch"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+)
+
+// guard authenticates users by the Username header
+func guard(r *http.Request) (string, bool) {
+	username := r.Header.Get("Username")
+	return username, username != ""
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-calendar-test.db")
+	datastore.Conn.AutoMigrate(&show.Show{}, &episode.Episode{}, &watch.Entry{}, &Token{})
+	r := router.NewRouter()
+	r.Use(router.FirewallMiddleware(router.FirewallConfig{
+		Guard: guard,
+		Only:  []string{"^/me/", "^/calendar$"},
+	}))
+	r.AddRoutes(Routes())
+	server = httptest.NewServer(r)
+	baseURL = server.URL
+	ret := m.Run()
+	datastore.Conn.DropTable(&show.Show{}, &episode.Episode{}, &watch.Entry{}, &Token{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, username string, method string, url string) *http.Response {
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "" {
+		request.Header.Set("Username", username)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func date(value string) *time.Time {
+	d, _ := time.Parse("2006-01-02", value)
+	return &d
+}
+
+func createEpisodes(t *testing.T, principal string) (episode.Episode, episode.Episode) {
+	watched := show.Show{Title: "Twin Peaks", Year: 1990}
+	other := show.Show{Title: "Northern Exposure", Year: 1990}
+	for _, s := range []*show.Show{&watched, &other} {
+		if err := datastore.StoreEntity(s, datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := datastore.StoreEntity(&watch.Entry{Principal: principal, ShowID: watched.ID}, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	first := episode.Episode{ShowID: watched.ID, Season: 1, Number: 1, Title: "Pilot", AirDate: date("2017-05-21")}
+	second := episode.Episode{ShowID: watched.ID, Season: 1, Number: 2, Title: "Traces to Nowhere", AirDate: date("2017-05-28")}
+	elsewhere := episode.Episode{ShowID: other.ID, Season: 1, Number: 1, AirDate: date("2017-05-21")}
+	for _, e := range []*episode.Episode{&first, &second, &elsewhere} {
+		if err := datastore.StoreEntity(e, datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return first, second
+}
+
+func TestCalendar(t *testing.T) {
+	first, _ := createEpisodes(t, "alice")
+
+	response := testEndpoint(t, "alice", "GET", fmt.Sprintf("%s/calendar?from=2017-05-20&to=2017-05-21", baseURL))
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var document struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Data) != 1 || document.Data[0].ID != fmt.Sprintf("%d", first.ID) {
+		t.Errorf("Expected the calendar to contain episode %d, got %v", first.ID, document.Data)
+	}
+	response = testEndpoint(t, "alice", "GET", fmt.Sprintf("%s/calendar?from=2017-05-20&to=2017-05-01", baseURL))
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+}
+
+func TestCalendarExport(t *testing.T) {
+	createEpisodes(t, "carol")
+	url := fmt.Sprintf("%s/calendar.ics?from=2017-05-01&to=2017-05-31", baseURL)
+
+	response := testEndpoint(t, "", "GET", url)
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnauthorized, response.StatusCode)
+	}
+	response = testEndpoint(t, "bob", "POST", fmt.Sprintf("%s/me/calendar-token", baseURL))
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	response = testEndpoint(t, "carol", "POST", fmt.Sprintf("%s/me/calendar-token", baseURL))
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var document struct {
+		Data struct {
+			Attributes struct {
+				Token string `json:"token"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	token := document.Data.Attributes.Token
+
+	response = testEndpoint(t, "", "GET", fmt.Sprintf("%s&token=%s", url, token))
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/calendar") {
+		t.Errorf("Expected an iCalendar feed, got %s", contentType)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := string(body)
+	if !strings.HasPrefix(feed, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(feed, "END:VCALENDAR\r\n") {
+		t.Errorf("Expected a VCALENDAR, got %q", feed)
+	}
+	if count := strings.Count(feed, "BEGIN:VEVENT"); count != 2 {
+		t.Errorf("Expected 2 events, got %d", count)
+	}
+	if !strings.Contains(feed, "SUMMARY:Twin Peaks S01E02 - Traces to Nowhere\r\n") {
+		t.Errorf("Expected the summary of the second episode, got %q", feed)
+	}
+	if !strings.Contains(feed, "DTSTART;VALUE=DATE:20170521\r\n") {
+		t.Errorf("Expected the air date of the first episode, got %q", feed)
+	}
+
+	// Renewing the token revokes the previous one
+	testEndpoint(t, "carol", "POST", fmt.Sprintf("%s/me/calendar-token", baseURL))
+	response = testEndpoint(t, "", "GET", fmt.Sprintf("%s&token=%s", url, token))
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnauthorized, response.StatusCode)
+	}
+}
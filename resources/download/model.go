@@ -0,0 +1,71 @@
+package download
+
+import (
+	"time"
+
+	"github.com/torrent-viewer/backend/downloader"
+)
+
+// Client is a download client torrents can be sent to.
+// Its password can be written but is never sent back.
+type Client struct {
+	ID        int        `jsonapi:"primary,download-clients" gorm:"primary_key"`
+	CreatedAt time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt *time.Time `jsonapi:"" sql:"index"`
+	Name      string     `jsonapi:"attr,name" valid:"required"`
+	Kind      string     `jsonapi:"attr,kind" valid:"in(transmission|qbittorrent),required"`
+	URL       string     `jsonapi:"attr,url" valid:"url,required"`
+	Username  string     `jsonapi:"attr,username"`
+	Password  string     `jsonapi:"attr,password,omitempty" secret:"true"`
+	Directory string     `jsonapi:"attr,directory"`
+	Category  string     `jsonapi:"attr,category"`
+	Version   int        `jsonapi:"" gorm:"not null;default:1"`
+}
+
+type Clients []*Client
+
+type ClientResource struct{}
+
+func (Client) TableName() string {
+	return "download_clients"
+}
+
+func (c Client) GetID() int {
+	return c.ID
+}
+
+func (c Client) GetVersion() int {
+	return c.Version
+}
+
+func (c *Client) SetVersion(version int) {
+	c.Version = version
+}
+
+// Config returns the configuration used to reach the download client
+func (c Client) Config() downloader.Config {
+	return downloader.Config{
+		Kind:      c.Kind,
+		URL:       c.URL,
+		Username:  c.Username,
+		Password:  c.Password,
+		Directory: c.Directory,
+		Category:  c.Category,
+	}
+}
+
+// Download is a torrent of a download client, identified by its info hash
+type Download struct {
+	Hash     string  `jsonapi:"primary,downloads"`
+	Name     string  `jsonapi:"attr,name"`
+	State    string  `jsonapi:"attr,state"`
+	Progress float64 `jsonapi:"attr,progress"`
+}
+
+// redact removes the password of the given clients before they are sent
+func redact(clients ...*Client) {
+	for _, c := range clients {
+		c.Password = ""
+	}
+}
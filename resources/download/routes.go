@@ -0,0 +1,328 @@
+package download
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/downloader"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/torrent"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+// ClientsList is the HTTP endpoint used to list Clients instances
+func (ClientResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Clients
+	var page requests.Pagination
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Client{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if pagination, err := requests.Paginate(&Client{}, r, trashed); err != nil {
+		responses.SendError(w, *err)
+		return
+	} else {
+		page = pagination
+	}
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, trashed, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	redact(entries...)
+	responses.SendEntities(w, serialized)
+}
+
+// ClientsStore is the HTTP endpoint used to create new Clients instances
+func (ClientResource) RouteStore(w http.ResponseWriter, r *http.Request) {
+	var client Client
+	if err := requests.ReceiveEntity(r, &client); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateEntity(&client); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.StoreEntity(&client, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/download-clients/%d", client.ID))
+	redact(&client)
+	responses.SendEntity(w, &client, http.StatusCreated)
+}
+
+// ClientsView is the HTTP endpoint used to show Clients instance by ID
+func (ClientResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var client Client
+	if err := datastore.FetchEntity(&client, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if requests.NotModified(r, &client) {
+		responses.SendNotModified(w, &client)
+		return
+	}
+	redact(&client)
+	responses.SendEntity(w, &client, http.StatusOK)
+}
+
+// ClientsUpdate is the HTTP endpoint used to update some attributes of a Client instance by its ID
+func (ClientResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var client Client
+	if err := datastore.FetchEntity(&client, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &client); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &client)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateFields(&client, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if client.ID != id {
+		responses.SendError(w, herr.UnmatchingIDsError)
+		return
+	}
+	if err := datastore.UpdateEntity(&client, fields, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	redact(&client)
+	responses.SendEntity(w, &client, http.StatusOK)
+}
+
+// ClientsDestroy is the HTTP endpoint used to delete a Client instance by its ID
+func (ClientResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var client Client
+	if err := datastore.FetchEntity(&client, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &client); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&client, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// ClientsRestore is the HTTP endpoint used to restore a soft-deleted Client instance by its ID
+func (ClientResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	client := Client{
+		ID: id,
+	}
+	if err := datastore.RestoreEntity(&client, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&client, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	redact(&client)
+	responses.SendEntity(w, &client, http.StatusOK)
+}
+
+// ClientsPurge is the HTTP endpoint used to permanently delete a Client instance by its ID
+func (ClientResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	client := Client{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&client, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Routes returns the routes of the download clients that are not part of
+// the resource itself
+func Routes() router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/download-clients/{id:[0-9]+}/check",
+			Handler: Check,
+			Method:  "POST",
+			Name:    "download-clients.check",
+		},
+		router.Route{
+			Path:    "/download-clients/{id:[0-9]+}/downloads",
+			Handler: Downloads,
+			Method:  "GET",
+			Name:    "download-clients.downloads",
+		},
+		router.Route{
+			Path:    "/torrents/{id:[0-9]+}/send",
+			Handler: Send,
+			Method:  "POST",
+			Name:    "torrents.send",
+		},
+	}
+}
+
+// Check is the HTTP endpoint used to ensure that a Client instance can be reached
+func Check(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var client Client
+	if err := datastore.FetchEntity(&client, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	remote, clientErr := connect(&client)
+	if clientErr == nil {
+		clientErr = remote.Check()
+	}
+	if clientErr != nil {
+		responses.SendError(w, clientError(clientErr))
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Downloads is the HTTP endpoint used to list the torrents of a Client
+// instance with their progress.
+// The hashes parameter restricts the list to the given comma-separated hashes.
+func Downloads(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var client Client
+	if err := datastore.FetchEntity(&client, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var hashes []string
+	if value := r.URL.Query().Get("hashes"); value != "" {
+		hashes = strings.Split(strings.ToLower(value), ",")
+	}
+	remote, clientErr := connect(&client)
+	var statuses []downloader.Status
+	if clientErr == nil {
+		statuses, clientErr = remote.Statuses(hashes...)
+	}
+	if clientErr != nil {
+		responses.SendError(w, clientError(clientErr))
+		return
+	}
+	serialized := make([]interface{}, len(statuses), len(statuses))
+	for i, s := range statuses {
+		serialized[i] = &Download{Hash: s.Hash, Name: s.Name, State: s.State, Progress: s.Progress}
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// Send is the HTTP endpoint used to send a Torrent instance to the download
+// client given as the linkage of the request body
+func Send(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var t torrent.Torrent
+	if err := datastore.FetchEntity(&t, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	ids, err := requests.ReceiveLinkage(r, "download-clients", false)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if len(ids) == 0 {
+		responses.SendError(w, herr.Error{
+			ID:     "missing-linkage",
+			Status: "422",
+			Title:  "Missing linkage",
+			Detail: "The download client to send the torrent to must be given",
+			Source: herr.ErrorSource{
+				Pointer: "/data",
+			},
+		})
+		return
+	}
+	var client Client
+	if err := datastore.FetchEntity(&client, ids[0]); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	remote, clientErr := connect(&client)
+	if clientErr == nil {
+		_, clientErr = remote.Add(downloader.Torrent{MagnetURI: t.Magnet()})
+	}
+	if clientErr != nil {
+		responses.SendError(w, clientError(clientErr))
+		return
+	}
+	if err := torrent.RecordDownload(&t, client.ID, downloader.StateQueued, 0); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &t, http.StatusOK)
+}
+
+// clientError reports that a download client failed to answer
+func clientError(err error) herr.Error {
+	return herr.Error{
+		ID:     "download-client-error",
+		Status: "502",
+		Title:  "Download Client Error",
+		Detail: err.Error(),
+	}
+}
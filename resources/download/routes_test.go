@@ -0,0 +1,294 @@
+package download
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/torrent"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+	// mutations are the mutations published during the tests
+	mutations []datastore.Mutation
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-download-test.db")
+	datastore.Conn.AutoMigrate(&Client{}, &torrent.Torrent{})
+	datastore.OnMutation(func(m datastore.Mutation) {
+		mutations = append(mutations, m)
+	})
+	r := router.NewRouter()
+	r.AddResource("download-clients", ClientResource{})
+	r.AddRoutes(Routes())
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/download-clients", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&Client{}, &torrent.Torrent{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func store(t *testing.T, kind string, url string) (*http.Response, map[string]interface{}) {
+	input := fmt.Sprintf(`{
+    "data": {
+      "type": "download-clients",
+      "attributes": {
+        "name": "Seedbox",
+        "kind": "%s",
+        "url": "%s",
+        "username": "admin",
+        "password": "secret"
+      }
+    }
+  }`, kind, url)
+	response := testEndpoint(t, "POST", baseURL, &input)
+	var document struct {
+		Data struct {
+			ID         string                 `json:"id"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"data"`
+	}
+	if response.StatusCode == http.StatusCreated {
+		if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+			t.Fatal(err)
+		}
+		document.Data.Attributes["id"] = document.Data.ID
+	}
+	return response, document.Data.Attributes
+}
+
+func TestClientsStore(t *testing.T) {
+	response, _ := store(t, "utorrent", "http://localhost:9091/transmission/rpc")
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	response, attributes := store(t, "transmission", "http://localhost:9091/transmission/rpc")
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	if _, ok := attributes["password"]; ok {
+		t.Error("Expected the password not to be sent back")
+	}
+	var c Client
+	if err := datastore.FetchEntity(&c, 1); err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "secret" {
+		t.Errorf("Expected the password to be stored, got %q", c.Password)
+	}
+}
+
+func TestClientsCheck(t *testing.T) {
+	transmission := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Transmission-Session-Id") == "" {
+			w.Header().Set("X-Transmission-Session-Id", "session")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Write([]byte(`{"result": "success", "arguments": {}}`))
+	}))
+	defer transmission.Close()
+
+	_, attributes := store(t, "transmission", transmission.URL)
+	response := testEndpoint(t, "POST", fmt.Sprintf("%s/%s/check", baseURL, attributes["id"]), nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	_, attributes = store(t, "transmission", "http://127.0.0.1:1/transmission/rpc")
+	response = testEndpoint(t, "POST", fmt.Sprintf("%s/%s/check", baseURL, attributes["id"]), nil)
+	if response.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadGateway, response.StatusCode)
+	}
+}
+
+func TestClientsDownloads(t *testing.T) {
+	qbittorrent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+			w.Write([]byte("Ok."))
+		case "/api/v2/torrents/info":
+			w.Write([]byte(`[{"hash": "C0FFEE", "name": "Pilot", "state": "downloading", "progress": 0.5}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer qbittorrent.Close()
+
+	response, attributes := store(t, "qbittorrent", qbittorrent.URL)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%s/downloads", baseURL, attributes["id"]), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var document struct {
+		Data []struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				State    string  `json:"state"`
+				Progress float64 `json:"progress"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Data) != 1 || document.Data[0].ID != "c0ffee" {
+		t.Fatalf("Expected the torrent of the client, got %v", document.Data)
+	}
+	if document.Data[0].Attributes.State != "downloading" || document.Data[0].Attributes.Progress != 0.5 {
+		t.Errorf("Expected a torrent half downloaded, got %v", document.Data[0].Attributes)
+	}
+}
+
+func TestTorrentsSend(t *testing.T) {
+	added := []string{}
+	transmission := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Transmission-Session-Id") == "" {
+			w.Header().Set("X-Transmission-Session-Id", "session")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		var request struct {
+			Method    string            `json:"method"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		added = append(added, request.Arguments["filename"])
+		w.Write([]byte(`{"result": "success", "arguments": {"torrent-added": {"hashString": "C12FE1C06BBA254A9DC9F519B335AA7C1367A88A"}}}`))
+	}))
+	defer transmission.Close()
+
+	tr := torrent.Torrent{
+		Name:     "Pilot",
+		InfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		Trackers: "udp://tracker.example.com:80",
+	}
+	if err := datastore.StoreEntity(&tr, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/torrents/%d/send", server.URL, tr.ID)
+	_, attributes := store(t, "transmission", transmission.URL)
+
+	input := `{"data": null}`
+	response := testEndpoint(t, "POST", url, &input)
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	input = fmt.Sprintf(`{"data": {"type": "download-clients", "id": "%s"}}`, attributes["id"])
+	response = testEndpoint(t, "POST", url, &input)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if len(added) != 1 || !strings.HasPrefix(added[0], "magnet:?xt=urn:btih:"+tr.InfoHash+"&") || !strings.Contains(added[0], "tr=udp") {
+		t.Errorf("Expected the magnet URI of the torrent to be sent, got %v", added)
+	}
+	if err := datastore.FetchEntity(&tr, tr.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%d", tr.DownloadClientID) != attributes["id"] || tr.DownloadState != "queued" || tr.Version != 1 {
+		t.Errorf("Expected the download to be recorded, got %+v", tr)
+	}
+
+	_, attributes = store(t, "transmission", "http://127.0.0.1:1/transmission/rpc")
+	input = fmt.Sprintf(`{"data": {"type": "download-clients", "id": "%s"}}`, attributes["id"])
+	response = testEndpoint(t, "POST", url, &input)
+	if response.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadGateway, response.StatusCode)
+	}
+}
+
+func TestSyncDownloads(t *testing.T) {
+	logins := 0
+	info := `[{"hash": "0123456789ABCDEF0123456789ABCDEF01234567", "name": "Pilot", "state": "downloading", "progress": 0.5}]`
+	qbittorrent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			logins++
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+			w.Write([]byte("Ok."))
+		case "/api/v2/torrents/info":
+			w.Write([]byte(info))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer qbittorrent.Close()
+
+	client := Client{Name: "Seedbox", Kind: "qbittorrent", URL: qbittorrent.URL}
+	if err := datastore.StoreEntity(&client, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	tr := torrent.Torrent{
+		Name:             "Pilot",
+		InfoHash:         "0123456789abcdef0123456789abcdef01234567",
+		DownloadClientID: client.ID,
+		DownloadState:    "queued",
+	}
+	if err := datastore.StoreEntity(&tr, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	mutations = nil
+	for i := 0; i < 2; i++ {
+		SyncDownloads()
+	}
+	if err := datastore.FetchEntity(&tr, tr.ID); err != nil {
+		t.Fatal(err)
+	}
+	if tr.DownloadState != "downloading" || tr.DownloadProgress != 0.5 {
+		t.Errorf("Expected a torrent half downloaded, got %q at %f", tr.DownloadState, tr.DownloadProgress)
+	}
+	if len(mutations) != 1 || mutations[0].Action != "progressed" || mutations[0].ID != tr.ID || mutations[0].After["download_progress"] != 0.5 {
+		t.Errorf("Expected the progress of the torrent to be published once, got %v", mutations)
+	}
+	if logins != 1 {
+		t.Errorf("Expected the session to be reused, got %d logins", logins)
+	}
+	client.Username = "admin"
+	if err := datastore.UpdateEntity(&client, []string{"Username"}, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	SyncDownloads()
+	if logins != 2 {
+		t.Errorf("Expected a new session once the client is updated, got %d logins", logins)
+	}
+
+	info = `[{"hash": "0123456789ABCDEF0123456789ABCDEF01234567", "name": "Pilot", "state": "uploading", "progress": 1}]`
+	mutations = nil
+	SyncDownloads()
+	if len(mutations) != 2 || mutations[0].Action != "progressed" || mutations[1].Action != "completed" {
+		t.Errorf("Expected the completion of the download to be published, got %v", mutations)
+	}
+}
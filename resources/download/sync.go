@@ -0,0 +1,86 @@
+package download
+
+import (
+	"log"
+	"sync"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/downloader"
+	"github.com/torrent-viewer/backend/resources/torrent"
+)
+
+// connection is a downloader.Client kept for a version of a Client
+type connection struct {
+	version int
+	remote  downloader.Client
+}
+
+var (
+	connectionsMutex sync.Mutex
+	connections      = make(map[int]connection)
+)
+
+// connect returns the downloader.Client reaching a Client.
+// Clients are kept until the Client is updated, so that sessions such as
+// the qBittorrent login are reused across requests.
+func connect(c *Client) (downloader.Client, error) {
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+	if conn, ok := connections[c.ID]; ok && conn.version == c.Version {
+		return conn.remote, nil
+	}
+	remote, err := downloader.New(c.Config())
+	if err != nil {
+		return nil, err
+	}
+	connections[c.ID] = connection{version: c.Version, remote: remote}
+	return remote, nil
+}
+
+// SyncDownloads copies the state and progress reported by the download
+// clients onto the torrents sent to them.
+// Torrents removed from their client keep their last known state.
+func SyncDownloads() {
+	var torrents torrent.Torrents
+	if err := datastore.FetchEntities(&torrents, "download_client_id <> 0"); err != nil {
+		log.Printf("Could not fetch downloaded torrents: %s\n", err.Detail)
+		return
+	}
+	byClient := make(map[int]torrent.Torrents)
+	for _, t := range torrents {
+		byClient[t.DownloadClientID] = append(byClient[t.DownloadClientID], t)
+	}
+	for id, downloads := range byClient {
+		var client Client
+		if err := datastore.FetchEntity(&client, id); err != nil {
+			log.Printf("Could not fetch download client %d: %s\n", id, err.Detail)
+			continue
+		}
+		hashes := make([]string, len(downloads), len(downloads))
+		for i, t := range downloads {
+			hashes[i] = t.InfoHash
+		}
+		remote, err := connect(&client)
+		var statuses []downloader.Status
+		if err == nil {
+			statuses, err = remote.Statuses(hashes...)
+		}
+		if err != nil {
+			log.Printf("Could not reach download client %d: %s\n", id, err)
+			continue
+		}
+		reported := make(map[string]downloader.Status)
+		for _, s := range statuses {
+			reported[s.Hash] = s
+		}
+		for _, t := range downloads {
+			s, ok := reported[t.InfoHash]
+			if !ok || s.State == t.DownloadState && s.Progress == t.DownloadProgress {
+				continue
+			}
+			if err := torrent.RecordDownload(t, id, s.State, s.Progress); err != nil {
+				log.Printf("Could not record the download of torrent %d: %s\n", t.ID, err.Detail)
+			}
+		}
+	}
+}
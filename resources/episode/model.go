@@ -1,7 +1,74 @@
 package episode
 
+import (
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/resources/profile"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/resources/torrent"
+)
+
 type Episode struct {
-	Name string `json:"name"`
+	ID        int        `jsonapi:"primary,episodes" gorm:"primary_key"`
+	CreatedAt time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt *time.Time `jsonapi:"" sql:"index"`
+	ShowID    int        `jsonapi:"attr,show_id" valid:"required" sql:"index"`
+	Season    int        `jsonapi:"attr,season"`
+	Number    int        `jsonapi:"attr,number" valid:"required"`
+	Title     string     `jsonapi:"attr,title"`
+	Summary   string     `jsonapi:"attr,summary" sql:"type:text"`
+	AirDate   *time.Time `jsonapi:"attr,air_date"`
+	Version   int        `jsonapi:"" gorm:"not null;default:1"`
 }
 
 type Episodes []*Episode
+
+type EpisodeResource struct{}
+
+func (Episode) TableName() string {
+	return "episodes"
+}
+
+func (e Episode) GetID() int {
+	return e.ID
+}
+
+func (e Episode) GetVersion() int {
+	return e.Version
+}
+
+func (e *Episode) SetVersion(version int) {
+	e.Version = version
+}
+
+func (Episode) Relationships() []datastore.Relationship {
+	return []datastore.Relationship{
+		{Name: "show", Type: "shows", ForeignKey: "ShowID"},
+		{Name: "torrents", Type: "torrents", ForeignKey: "EpisodeID", ToMany: true, Optional: true},
+	}
+}
+
+// bestTorrent returns the torrent of the episode preferred by the quality
+// profile of its show, or nil if the profile accepts none of them.
+// Every torrent is accepted if the show has no quality profile.
+func bestTorrent(e *Episode) (*torrent.Torrent, *herr.Error) {
+	var s show.Show
+	if err := datastore.FetchEntity(&s, e.ShowID); err != nil {
+		return nil, err
+	}
+	var p profile.Profile
+	if s.QualityProfileID != 0 {
+		// A profile in the trash is not applied anymore
+		if err := datastore.FetchEntity(&p, s.QualityProfileID); err != nil && err.Status != "404" {
+			return nil, err
+		}
+	}
+	var torrents torrent.Torrents
+	if err := datastore.FetchEntities(&torrents, "episode_id = ?", e.ID); err != nil {
+		return nil, err
+	}
+	return p.Best(torrents), nil
+}
@@ -0,0 +1,243 @@
+package episode
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/base"
+	"github.com/torrent-viewer/backend/resources/watch"
+	"github.com/torrent-viewer/backend/responses"
+)
+
+// EpisodesList is the HTTP endpoint used to list Episodes instances
+func (EpisodeResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Episodes
+	var page requests.Pagination
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	watched, err := watch.ParseWatched(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Episode{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	scopes := append([]datastore.Scope{trashed}, watched...)
+	if pagination, err := requests.Paginate(&Episode{}, r, scopes...); err != nil {
+		responses.SendError(w, *err)
+		return
+	} else {
+		page = pagination
+	}
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, append(scopes, sort)...); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// EpisodesStore is the HTTP endpoint used to create new Episodes instances
+func (EpisodeResource) RouteStore(w http.ResponseWriter, r *http.Request) {
+	var episode Episode
+	if err := requests.ReceiveEntity(r, &episode); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateEntity(&episode); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&episode); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.StoreEntity(&episode, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/episodes/%d", episode.ID))
+	responses.SendEntity(w, &episode, http.StatusCreated)
+}
+
+// EpisodesView is the HTTP endpoint used to show Episodes instance by ID
+func (EpisodeResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	includes, err := requests.ParseInclude(r, "best-torrent")
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var episode Episode
+	if err := datastore.FetchEntity(&episode, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if len(includes) == 0 {
+		if requests.NotModified(r, &episode) {
+			responses.SendNotModified(w, &episode)
+			return
+		}
+		responses.SendEntity(w, &episode, http.StatusOK)
+		return
+	}
+	// The best torrent changes without a new version of the episode,
+	// so that compound documents are always sent
+	best, err := bestTorrent(&episode)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	inclusion := responses.Inclusion{Relationship: "best-torrent"}
+	if best != nil {
+		inclusion.Entity = best
+	}
+	responses.SendCompoundEntity(w, &episode, http.StatusOK, inclusion)
+}
+
+// EpisodesUpdate is the HTTP endpoint used to update some attributes of an Episode instance by its ID
+func (EpisodeResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var episode Episode
+	if err := datastore.FetchEntity(&episode, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &episode); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &episode)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateFields(&episode, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&episode); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if episode.ID != id {
+		responses.SendError(w, herr.UnmatchingIDsError)
+		return
+	}
+	if err := datastore.UpdateEntity(&episode, fields, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &episode, http.StatusOK)
+}
+
+// EpisodesDestroy is the HTTP endpoint used to delete an Episode instance by its ID
+func (EpisodeResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var episode Episode
+	if err := datastore.FetchEntity(&episode, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &episode); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&episode, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// EpisodesRestore is the HTTP endpoint used to restore a soft-deleted Episode instance by its ID
+func (EpisodeResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	episode := Episode{
+		ID: id,
+	}
+	if err := datastore.RestoreEntity(&episode, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&episode, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &episode, http.StatusOK)
+}
+
+// EpisodesPurge is the HTTP endpoint used to permanently delete an Episode instance by its ID
+func (EpisodeResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	episode := Episode{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&episode, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Relationships returns the names of the relationships of Episode instances
+func (EpisodeResource) Relationships() []string {
+	return datastore.RelationshipNames(Episode{})
+}
+
+// EpisodesViewRelated is the HTTP endpoint used to show the resources related to an Episode instance
+func (EpisodeResource) RouteViewRelated(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelated(w, r, &Episode{})
+}
+
+// EpisodesViewRelationship is the HTTP endpoint used to show the linkage of an Episode relationship
+func (EpisodeResource) RouteViewRelationship(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelationship(w, r, &Episode{})
+}
+
+// EpisodesUpdateRelationship is the HTTP endpoint used to replace the linkage of an Episode relationship
+func (EpisodeResource) RouteUpdateRelationship(w http.ResponseWriter, r *http.Request) {
+	base.UpdateRelationship(w, r, &Episode{})
+}
+
+// EpisodesAddRelationship is the HTTP endpoint used to add links to an Episode relationship
+func (EpisodeResource) RouteAddRelationship(w http.ResponseWriter, r *http.Request) {
+	base.AddRelationship(w, r, &Episode{})
+}
+
+// EpisodesRemoveRelationship is the HTTP endpoint used to remove links from an Episode relationship
+func (EpisodeResource) RouteRemoveRelationship(w http.ResponseWriter, r *http.Request) {
+	base.RemoveRelationship(w, r, &Episode{})
+}
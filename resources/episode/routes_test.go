@@ -0,0 +1,433 @@
+package episode
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/shwoodard/jsonapi"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/profile"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/resources/torrent"
+	"github.com/torrent-viewer/backend/resources/watch"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server   *httptest.Server
+	baseURL  string
+	showsURL string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-episode-test.db")
+	datastore.Conn.AutoMigrate(&show.Show{}, &Episode{}, &torrent.Torrent{}, &profile.Profile{}, &watch.Mark{})
+	datastore.Register(&show.Show{}, &Episode{}, &torrent.Torrent{}, &profile.Profile{})
+	r := router.NewRouter()
+	// Users are optional, and identified by the Username header
+	r.Use(router.FirewallMiddleware(router.FirewallConfig{
+		Guard: func(r *http.Request) (string, bool) {
+			return r.Header.Get("Username"), true
+		},
+		Only: []string{"^/episodes"},
+	}))
+	r.AddResource("shows", show.ShowResource{})
+	r.AddResource("episodes", EpisodeResource{})
+	r.AddResource("torrents", torrent.TorrentResource{})
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/episodes", server.URL)
+	showsURL = fmt.Sprintf("%s/shows", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&show.Show{}, &Episode{}, &torrent.Torrent{}, &profile.Profile{}, &watch.Mark{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func createShow(t *testing.T, title string) show.Show {
+	input := fmt.Sprintf(`{
+    "data": {
+      "type": "shows",
+      "attributes": {
+        "title": "%s",
+        "year": 2008
+      }
+    }
+  }`, title)
+	response := testEndpoint(t, "POST", showsURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var s show.Show
+	if err := jsonapi.UnmarshalPayload(response.Body, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func createEpisode(t *testing.T, showID int, number int) Episode {
+	input := fmt.Sprintf(`{
+    "data": {
+      "type": "episodes",
+      "attributes": {
+        "show_id": %d,
+        "season": 1,
+        "number": %d,
+        "title": "Pilot"
+      }
+    }
+  }`, showID, number)
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var e Episode
+	if err := jsonapi.UnmarshalPayload(response.Body, &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func linkage(t *testing.T, response *http.Response) []string {
+	var document struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	var many []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(document.Data, &many); err == nil {
+		ids := []string{}
+		for _, identifier := range many {
+			ids = append(ids, identifier.ID)
+		}
+		return ids
+	}
+	var one struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(document.Data, &one); err != nil || one.ID == "" {
+		return []string{}
+	}
+	return []string{one.ID}
+}
+
+func TestEpisodesStore(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "episodes",
+      "attributes": {
+        "show_id": 2147483647,
+        "number": 1
+      }
+    }
+  }`
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+	s := createShow(t, "Breaking Bad")
+	createEpisode(t, s.ID, 1)
+}
+
+func TestEpisodesRelationships(t *testing.T) {
+	first := createShow(t, "Breaking Bad")
+	second := createShow(t, "Better Call Saul")
+	e := createEpisode(t, first.ID, 1)
+
+	response := testEndpoint(t, "GET", fmt.Sprintf("%s/%d/relationships/show", baseURL, e.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if ids := linkage(t, response); len(ids) != 1 || ids[0] != fmt.Sprintf("%d", first.ID) {
+		t.Errorf("Expected episode to be linked to show %d, got %v", first.ID, ids)
+	}
+
+	input := fmt.Sprintf(`{"data": {"type": "shows", "id": "%d"}}`, second.ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d/relationships/show", baseURL, e.ID), &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	input = `{"data": null}`
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d/relationships/show", baseURL, e.ID), &input)
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusForbidden, response.StatusCode)
+	}
+	input = fmt.Sprintf(`{"data": {"type": "episodes", "id": "%d"}}`, second.ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d/relationships/show", baseURL, e.ID), &input)
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusConflict, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/relationships/episodes", showsURL, second.ID), nil)
+	if ids := linkage(t, response); len(ids) != 1 || ids[0] != fmt.Sprintf("%d", e.ID) {
+		t.Errorf("Expected show %d to be linked to episode %d, got %v", second.ID, e.ID, ids)
+	}
+
+	input = fmt.Sprintf(`{"data": [{"type": "episodes", "id": "%d"}]}`, e.ID)
+	response = testEndpoint(t, "POST", fmt.Sprintf("%s/%d/relationships/episodes", showsURL, first.ID), &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "DELETE", fmt.Sprintf("%s/%d/relationships/episodes", showsURL, first.ID), &input)
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusForbidden, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/relationships/episodes", showsURL, second.ID), nil)
+	if ids := linkage(t, response); len(ids) != 0 {
+		t.Errorf("Expected show %d not to be linked to any episode, got %v", second.ID, ids)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/relationships/torrents", showsURL, second.ID), nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+}
+
+func TestEpisodesTorrents(t *testing.T) {
+	s := createShow(t, "Breaking Bad")
+	first := createEpisode(t, s.ID, 1)
+	second := createEpisode(t, s.ID, 2)
+	torrents := make([]torrent.Torrent, 2)
+	for i := range torrents {
+		torrents[i] = torrent.Torrent{Name: fmt.Sprintf("Breaking.Bad.S01E01.%d", i), InfoHash: fmt.Sprintf("%040d", i+1)}
+		if err := datastore.StoreEntity(&torrents[i], datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	torrentsURL := fmt.Sprintf("%s/%d/relationships/torrents", baseURL, first.ID)
+
+	// A missing torrent leaves the linkage unchanged
+	input := fmt.Sprintf(`{"data": [{"type": "torrents", "id": "%d"}, {"type": "torrents", "id": "2147483647"}]}`, torrents[0].ID)
+	response := testEndpoint(t, "POST", torrentsURL, &input)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", torrentsURL, nil)
+	if ids := linkage(t, response); len(ids) != 0 {
+		t.Errorf("Expected episode %d not to be linked to any torrent, got %v", first.ID, ids)
+	}
+
+	input = fmt.Sprintf(`{"data": [{"type": "torrents", "id": "%d"}, {"type": "torrents", "id": "%d"}]}`, torrents[0].ID, torrents[1].ID)
+	response = testEndpoint(t, "PATCH", torrentsURL, &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	// A mis-matched torrent is moved to the right episode
+	input = fmt.Sprintf(`{"data": {"type": "episodes", "id": "%d"}}`, second.ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/torrents/%d/relationships/episode", server.URL, torrents[1].ID), &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", torrentsURL, nil)
+	if ids := linkage(t, response); len(ids) != 1 || ids[0] != fmt.Sprintf("%d", torrents[0].ID) {
+		t.Errorf("Expected episode %d to be linked to torrent %d, got %v", first.ID, torrents[0].ID, ids)
+	}
+	input = fmt.Sprintf(`{"data": [{"type": "torrents", "id": "%d"}]}`, torrents[0].ID)
+	response = testEndpoint(t, "DELETE", torrentsURL, &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", torrentsURL, nil)
+	if ids := linkage(t, response); len(ids) != 0 {
+		t.Errorf("Expected episode %d not to be linked to any torrent, got %v", first.ID, ids)
+	}
+}
+
+func TestEpisodesRelated(t *testing.T) {
+	s := createShow(t, "The Wire")
+	first := createEpisode(t, s.ID, 1)
+	second := createEpisode(t, s.ID, 2)
+
+	response := testEndpoint(t, "GET", fmt.Sprintf("%s/%d/show", baseURL, first.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var related show.Show
+	if err := jsonapi.UnmarshalPayload(response.Body, &related); err != nil {
+		t.Error(err)
+	} else if related.ID != s.ID {
+		t.Errorf("Expected show %d, got show %d", s.ID, related.ID)
+	}
+
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/episodes?sort=-number", showsURL, s.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if ids := linkage(t, response); len(ids) != 2 || ids[0] != fmt.Sprintf("%d", second.ID) || ids[1] != fmt.Sprintf("%d", first.ID) {
+		t.Errorf("Expected episodes %d and %d, got %v", second.ID, first.ID, ids)
+	}
+	torrents := []torrent.Torrent{
+		{Name: "The.Wire.S01E01.720p", InfoHash: fmt.Sprintf("%040x", 0xa1), EpisodeID: first.ID, Size: 2000},
+		{Name: "The.Wire.S01E01.1080p", InfoHash: fmt.Sprintf("%040x", 0xa2), EpisodeID: first.ID, Size: 4000},
+	}
+	for i := range torrents {
+		if err := datastore.StoreEntity(&torrents[i], datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/torrents/%d/episode", server.URL, torrents[0].ID), nil)
+	var episode Episode
+	if err := jsonapi.UnmarshalPayload(response.Body, &episode); err != nil {
+		t.Error(err)
+	} else if episode.ID != first.ID {
+		t.Errorf("Expected episode %d, got episode %d", first.ID, episode.ID)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/torrents?sort=-size", baseURL, first.ID), nil)
+	if ids := linkage(t, response); len(ids) != 2 || ids[0] != fmt.Sprintf("%d", torrents[1].ID) {
+		t.Errorf("Expected torrents %d and %d, got %v", torrents[1].ID, torrents[0].ID, ids)
+	}
+
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/episodes?sort=unknown", showsURL, s.ID), nil)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+}
+
+func TestEpisodesBestTorrent(t *testing.T) {
+	s := createShow(t, "The Sopranos")
+	p := profile.Profile{Name: "HD", Resolutions: "720p"}
+	if err := datastore.StoreEntity(&p, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.FetchEntity(&s, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.QualityProfileID = p.ID
+	if err := datastore.UpdateEntity(&s, []string{"QualityProfileID"}, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	first := createEpisode(t, s.ID, 1)
+	second := createEpisode(t, s.ID, 2)
+	torrents := []torrent.Torrent{
+		{Name: "The.Sopranos.S01E01.1080p-NTb", InfoHash: fmt.Sprintf("%040x", 0xb1), EpisodeID: first.ID},
+		{Name: "The.Sopranos.S01E01.720p-CTU", InfoHash: fmt.Sprintf("%040x", 0xb2), EpisodeID: first.ID},
+		{Name: "The.Sopranos.S01E02.1080p-NTb", InfoHash: fmt.Sprintf("%040x", 0xb3), EpisodeID: second.ID},
+	}
+	for i := range torrents {
+		if err := datastore.StoreEntity(&torrents[i], datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var document struct {
+		Data struct {
+			Relationships map[string]struct {
+				Data *struct {
+					Type string `json:"type"`
+					ID   string `json:"id"`
+				} `json:"data"`
+			} `json:"relationships"`
+		} `json:"data"`
+		Included []struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		} `json:"included"`
+	}
+	response := testEndpoint(t, "GET", fmt.Sprintf("%s/%d?include=best-torrent", baseURL, first.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	best := document.Data.Relationships["best-torrent"].Data
+	expected := fmt.Sprintf("%d", torrents[1].ID)
+	if best == nil || best.Type != "torrents" || best.ID != expected {
+		t.Errorf("Expected torrent %s to be the best, got %v", expected, best)
+	}
+	if len(document.Included) != 1 || document.Included[0].ID != expected {
+		t.Errorf("Expected torrent %s to be included, got %v", expected, document.Included)
+	}
+
+	// No torrent of the second episode is accepted by the profile
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d?include=best-torrent", baseURL, second.ID), nil)
+	document.Included = nil
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if best := document.Data.Relationships["best-torrent"].Data; best != nil || len(document.Included) != 0 {
+		t.Errorf("Expected no best torrent, got %v", best)
+	}
+
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d?include=show", baseURL, first.ID), nil)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+}
+
+func TestEpisodesWatchedFilter(t *testing.T) {
+	s := createShow(t, "The Sopranos")
+	first := createEpisode(t, s.ID, 1)
+	second := createEpisode(t, s.ID, 2)
+	if err := datastore.StoreEntity(&watch.Mark{Principal: "alice", EpisodeID: first.ID}, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.StoreEntity(&watch.Mark{Principal: "bob", EpisodeID: second.ID}, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	list := func(username string, watched string) *http.Response {
+		url := fmt.Sprintf("%s?filter[watched]=%s", baseURL, watched)
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if username != "" {
+			request.Header.Set("Username", username)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	response := list("", "true")
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnauthorized, response.StatusCode)
+	}
+	response = list("alice", "maybe")
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+	response = list("alice", "true")
+	if ids := linkage(t, response); len(ids) != 1 || ids[0] != fmt.Sprintf("%d", first.ID) {
+		t.Errorf("Expected episode %d to be watched, got %v", first.ID, ids)
+	}
+	response = list("alice", "false")
+	if ids := linkage(t, response); containsID(ids, first.ID) || !containsID(ids, second.ID) {
+		t.Errorf("Expected episode %d not to be watched, got %v", second.ID, ids)
+	}
+}
+
+func containsID(ids []string, id int) bool {
+	for _, i := range ids {
+		if i == fmt.Sprintf("%d", id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+)
+
+// Profile describes the releases wanted for the episodes of a show.
+// Lists are comma-separated values, an empty list allows everything.
+// Sizes are in bytes, a size of 0 is not bounded.
+type Profile struct {
+	ID              int        `jsonapi:"primary,quality-profiles" gorm:"primary_key"`
+	CreatedAt       time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt       time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt       *time.Time `jsonapi:"" sql:"index"`
+	Name            string     `jsonapi:"attr,name" valid:"required"`
+	Resolutions     string     `jsonapi:"attr,resolutions"`
+	Sources         string     `jsonapi:"attr,sources"`
+	Codecs          string     `jsonapi:"attr,codecs"`
+	MinSize         int64      `jsonapi:"attr,min_size"`
+	MaxSize         int64      `jsonapi:"attr,max_size"`
+	PreferredGroups string     `jsonapi:"attr,preferred_groups"`
+	BlockedGroups   string     `jsonapi:"attr,blocked_groups"`
+	RequiredWords   string     `jsonapi:"attr,required_words"`
+	ForbiddenWords  string     `jsonapi:"attr,forbidden_words"`
+	Version         int        `jsonapi:"" gorm:"not null;default:1"`
+}
+
+type Profiles []*Profile
+
+type ProfileResource struct{}
+
+func (Profile) TableName() string {
+	return "quality_profiles"
+}
+
+func (p Profile) GetID() int {
+	return p.ID
+}
+
+func (p Profile) GetVersion() int {
+	return p.Version
+}
+
+func (p *Profile) SetVersion(version int) {
+	p.Version = version
+}
+
+func (Profile) Relationships() []datastore.Relationship {
+	return []datastore.Relationship{
+		{Name: "shows", Type: "shows", ForeignKey: "QualityProfileID", ToMany: true, Optional: true},
+	}
+}
+
+// checkSizes ensures that the size bounds of the profile are consistent
+func checkSizes(p *Profile) *herr.Error {
+	var pointer string
+	if p.MinSize < 0 {
+		pointer = "/data/attributes/min_size"
+	} else if p.MaxSize < 0 || (p.MaxSize != 0 && p.MaxSize < p.MinSize) {
+		pointer = "/data/attributes/max_size"
+	} else {
+		return nil
+	}
+	return &herr.Error{
+		ID:     "invalid-attribute",
+		Status: "422",
+		Title:  "Invalid Attribute",
+		Detail: "The size bounds must be positive, and the maximum size must not be lower than the minimum size",
+		Source: herr.ErrorSource{
+			Pointer: pointer,
+		},
+	}
+}
@@ -0,0 +1,216 @@
+package profile
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/base"
+	"github.com/torrent-viewer/backend/responses"
+)
+
+// ProfilesList is the HTTP endpoint used to list Profiles instances
+func (ProfileResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Profiles
+	var page requests.Pagination
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Profile{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if pagination, err := requests.Paginate(&Profile{}, r, trashed); err != nil {
+		responses.SendError(w, *err)
+		return
+	} else {
+		page = pagination
+	}
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, trashed, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// ProfilesStore is the HTTP endpoint used to create new Profiles instances
+func (ProfileResource) RouteStore(w http.ResponseWriter, r *http.Request) {
+	var profile Profile
+	if err := requests.ReceiveEntity(r, &profile); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateEntity(&profile); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := checkSizes(&profile); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.StoreEntity(&profile, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/quality-profiles/%d", profile.ID))
+	responses.SendEntity(w, &profile, http.StatusCreated)
+}
+
+// ProfilesView is the HTTP endpoint used to show Profiles instance by ID
+func (ProfileResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var profile Profile
+	if err := datastore.FetchEntity(&profile, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if requests.NotModified(r, &profile) {
+		responses.SendNotModified(w, &profile)
+		return
+	}
+	responses.SendEntity(w, &profile, http.StatusOK)
+}
+
+// ProfilesUpdate is the HTTP endpoint used to update some attributes of a Profile instance by its ID
+func (ProfileResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var profile Profile
+	if err := datastore.FetchEntity(&profile, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &profile); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &profile)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateFields(&profile, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := checkSizes(&profile); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if profile.ID != id {
+		responses.SendError(w, herr.UnmatchingIDsError)
+		return
+	}
+	if err := datastore.UpdateEntity(&profile, fields, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &profile, http.StatusOK)
+}
+
+// ProfilesDestroy is the HTTP endpoint used to delete a Profile instance by its ID
+func (ProfileResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var profile Profile
+	if err := datastore.FetchEntity(&profile, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &profile); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&profile, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// ProfilesRestore is the HTTP endpoint used to restore a soft-deleted Profile instance by its ID
+func (ProfileResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	profile := Profile{
+		ID: id,
+	}
+	if err := datastore.RestoreEntity(&profile, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&profile, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &profile, http.StatusOK)
+}
+
+// ProfilesPurge is the HTTP endpoint used to permanently delete a Profile instance by its ID
+func (ProfileResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	profile := Profile{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&profile, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Relationships returns the names of the relationships of Profile instances
+func (ProfileResource) Relationships() []string {
+	return datastore.RelationshipNames(Profile{})
+}
+
+// ProfilesViewRelated is the HTTP endpoint used to show the resources related to a Profile instance
+func (ProfileResource) RouteViewRelated(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelated(w, r, &Profile{})
+}
+
+// ProfilesViewRelationship is the HTTP endpoint used to show the linkage of a Profile relationship
+func (ProfileResource) RouteViewRelationship(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelationship(w, r, &Profile{})
+}
+
+// ProfilesUpdateRelationship is the HTTP endpoint used to replace the linkage of a Profile relationship
+func (ProfileResource) RouteUpdateRelationship(w http.ResponseWriter, r *http.Request) {
+	base.UpdateRelationship(w, r, &Profile{})
+}
+
+// ProfilesAddRelationship is the HTTP endpoint used to add links to a Profile relationship
+func (ProfileResource) RouteAddRelationship(w http.ResponseWriter, r *http.Request) {
+	base.AddRelationship(w, r, &Profile{})
+}
+
+// ProfilesRemoveRelationship is the HTTP endpoint used to remove links from a Profile relationship
+func (ProfileResource) RouteRemoveRelationship(w http.ResponseWriter, r *http.Request) {
+	base.RemoveRelationship(w, r, &Profile{})
+}
@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/shwoodard/jsonapi"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server   *httptest.Server
+	baseURL  string
+	showsURL string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-profile-test.db")
+	datastore.Conn.AutoMigrate(&show.Show{}, &Profile{})
+	datastore.Register(&show.Show{}, &Profile{})
+	r := router.NewRouter()
+	r.AddResource("shows", show.ShowResource{})
+	r.AddResource("quality-profiles", ProfileResource{})
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/quality-profiles", server.URL)
+	showsURL = fmt.Sprintf("%s/shows", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&show.Show{}, &Profile{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestProfilesStore(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "quality-profiles",
+      "attributes": {
+        "name": "HD",
+        "min_size": 2000,
+        "max_size": 1000
+      }
+    }
+  }`
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	input = `{
+    "data": {
+      "type": "quality-profiles",
+      "attributes": {
+        "name": "HD",
+        "resolutions": "1080p,720p",
+        "blocked_groups": "YIFY",
+        "max_size": 4000000000
+      }
+    }
+  }`
+	response = testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+}
+
+func TestProfilesShows(t *testing.T) {
+	p := Profile{Name: "SD"}
+	if err := datastore.StoreEntity(&p, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	s := show.Show{Title: "The Wire", Year: 2002}
+	if err := datastore.StoreEntity(&s, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	input := fmt.Sprintf(`{"data": {"type": "quality-profiles", "id": "%d"}}`, p.ID)
+	response := testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d/relationships/quality-profile", showsURL, s.ID), &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d/quality-profile", showsURL, s.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var related Profile
+	if err := jsonapi.UnmarshalPayload(response.Body, &related); err != nil {
+		t.Fatal(err)
+	}
+	if related.ID != p.ID {
+		t.Errorf("Expected show to use profile %d, got %d", p.ID, related.ID)
+	}
+
+	// Unlike the show of an episode, the profile of a show can be removed
+	input = fmt.Sprintf(`{"data": [{"type": "shows", "id": "%d"}]}`, s.ID)
+	response = testEndpoint(t, "DELETE", fmt.Sprintf("%s/%d/relationships/shows", baseURL, p.ID), &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	if err := datastore.FetchEntity(&s, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.QualityProfileID != 0 {
+		t.Errorf("Expected show not to use any profile, got %d", s.QualityProfileID)
+	}
+}
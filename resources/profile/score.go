@@ -0,0 +1,134 @@
+package profile
+
+import (
+	"strings"
+
+	"github.com/torrent-viewer/backend/resources/torrent"
+)
+
+// Weights of the criteria of a profile when scoring a release.
+// Values listed first in the resolutions, sources and codecs of a profile
+// are preferred over the following ones.
+const (
+	preferredGroupWeight = 10000
+	resolutionWeight     = 1000
+	sourceWeight         = 100
+	codecWeight          = 10
+)
+
+// Score rates how well a torrent matches the profile, the higher the better.
+// It returns false if the profile does not accept the torrent.
+func (p Profile) Score(t torrent.Torrent) (int, bool) {
+	name := releaseWords(t.Name)
+	group := strings.ToLower(releaseGroup(t.Name))
+	if p.MinSize != 0 && t.Size < p.MinSize || p.MaxSize != 0 && t.Size > p.MaxSize {
+		return 0, false
+	}
+	for _, word := range list(p.RequiredWords) {
+		if !containsWord(name, word) {
+			return 0, false
+		}
+	}
+	for _, word := range list(p.ForbiddenWords) {
+		if containsWord(name, word) {
+			return 0, false
+		}
+	}
+	for _, blocked := range list(p.BlockedGroups) {
+		if group != "" && strings.ToLower(blocked) == group {
+			return 0, false
+		}
+	}
+	score := 0
+	for _, preferred := range list(p.PreferredGroups) {
+		if group != "" && strings.ToLower(preferred) == group {
+			score += preferredGroupWeight
+		}
+	}
+	criteria := []struct {
+		values string
+		weight int
+	}{
+		{p.Resolutions, resolutionWeight},
+		{p.Sources, sourceWeight},
+		{p.Codecs, codecWeight},
+	}
+	for _, c := range criteria {
+		values := list(c.values)
+		if len(values) == 0 {
+			continue
+		}
+		rank := -1
+		for i, value := range values {
+			if containsWord(name, value) {
+				rank = i
+				break
+			}
+		}
+		if rank < 0 {
+			return 0, false
+		}
+		score += (len(values) - rank) * c.weight
+	}
+	return score, true
+}
+
+// Best returns the torrent accepted by the profile with the highest score,
+// or nil if no torrent is accepted.
+// Torrents of equal score are ranked by their number of seeders.
+func (p Profile) Best(torrents torrent.Torrents) *torrent.Torrent {
+	var best *torrent.Torrent
+	bestScore := 0
+	for _, t := range torrents {
+		score, ok := p.Score(*t)
+		if !ok {
+			continue
+		}
+		if best == nil || score > bestScore || score == bestScore && t.Seeders > best.Seeders {
+			best = t
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// list returns the values of a comma-separated list
+func list(values string) []string {
+	result := []string{}
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// releaseWords returns the lower-case words of a release name, each of them
+// surrounded by dots
+func releaseWords(name string) string {
+	return "." + strings.Join(strings.FieldsFunc(strings.ToLower(name), isSeparator), ".") + "."
+}
+
+// containsWord reports whether the words of a release name contain the given
+// word, which may be made of several words such as "web-dl"
+func containsWord(words string, word string) bool {
+	return strings.Contains(words, releaseWords(word))
+}
+
+// releaseGroup returns the group of a release, named after its last dash,
+// or an empty string if the name does not end with a group
+func releaseGroup(name string) string {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return ""
+	}
+	group := strings.TrimSpace(name[i+1:])
+	if strings.ContainsAny(group, " .") {
+		return ""
+	}
+	return group
+}
+
+func isSeparator(r rune) bool {
+	return strings.ContainsRune(" .-_[](){}", r)
+}
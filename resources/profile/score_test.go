@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/torrent-viewer/backend/resources/torrent"
+)
+
+func TestScore(t *testing.T) {
+	p := Profile{
+		Resolutions:     "1080p, 720p",
+		Sources:         "web-dl, hdtv",
+		Codecs:          "x265, x264",
+		MinSize:         100,
+		MaxSize:         5000,
+		PreferredGroups: "NTb",
+		BlockedGroups:   "YIFY",
+		RequiredWords:   "",
+		ForbiddenWords:  "cam, sample",
+	}
+	tests := []struct {
+		name     string
+		size     int64
+		accepted bool
+		score    int
+	}{
+		{"Breaking.Bad.S01E01.1080p.WEB-DL.x265-NTb", 1000, true, 10000 + 2000 + 200 + 20},
+		{"Breaking.Bad.S01E01.720p.HDTV.x264-CTU", 1000, true, 1000 + 100 + 10},
+		{"Breaking Bad S01E01 720p HDTV x264", 1000, true, 1000 + 100 + 10},
+		{"Breaking.Bad.S01E01.480p.HDTV.x264-CTU", 1000, false, 0},
+		{"Breaking.Bad.S01E01.1080p.WEBRip.x264-CTU", 1000, false, 0},
+		{"Breaking.Bad.S01E01.1080p.WEB-DL.x264-YIFY", 1000, false, 0},
+		{"Breaking.Bad.S01E01.1080p.WEB-DL.x264.Sample-CTU", 1000, false, 0},
+		{"Breaking.Bad.S01E01.1080p.WEB-DL.x264-CTU", 50, false, 0},
+		{"Breaking.Bad.S01E01.1080p.WEB-DL.x264-CTU", 6000, false, 0},
+	}
+	for _, test := range tests {
+		score, accepted := p.Score(torrent.Torrent{Name: test.name, Size: test.size})
+		if accepted != test.accepted || score != test.score {
+			t.Errorf("Expected %s to be accepted: %t with score %d, got %t with score %d", test.name, test.accepted, test.score, accepted, score)
+		}
+	}
+	p.RequiredWords = "proper"
+	if _, accepted := p.Score(torrent.Torrent{Name: "Breaking.Bad.S01E01.720p.HDTV.x264-CTU", Size: 1000}); accepted {
+		t.Error("Expected a release without the required words to be rejected")
+	}
+}
+
+func TestBest(t *testing.T) {
+	p := Profile{Resolutions: "1080p,720p"}
+	torrents := torrent.Torrents{
+		{ID: 1, Name: "Pilot.480p-CTU"},
+		{ID: 2, Name: "Pilot.720p-CTU", Seeders: 50},
+		{ID: 3, Name: "Pilot.1080p-CTU", Seeders: 1},
+		{ID: 4, Name: "Pilot.1080p-NTb", Seeders: 10},
+	}
+	if best := p.Best(torrents); best == nil || best.ID != 4 {
+		t.Errorf("Expected torrent 4 to be the best, got %v", best)
+	}
+	if best := p.Best(torrents[:1]); best != nil {
+		t.Errorf("Expected no torrent to be accepted, got %v", best)
+	}
+	if best := (Profile{}).Best(torrents); best == nil || best.ID != 2 {
+		t.Errorf("Expected the torrent with the most seeders to be the best without criteria, got %v", best)
+	}
+}
@@ -0,0 +1,94 @@
+package season
+
+import (
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/resources/stats"
+)
+
+type Season struct {
+	ID         int        `jsonapi:"primary,seasons" gorm:"primary_key"`
+	CreatedAt  time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt  time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt  *time.Time `jsonapi:"" sql:"index"`
+	ShowID     int        `jsonapi:"attr,show_id" valid:"required" sql:"index"`
+	Number     int        `jsonapi:"attr,number"`
+	Title      string     `jsonapi:"attr,title"`
+	PosterPath string     `jsonapi:"attr,poster_path"`
+	AirYear    int        `jsonapi:"attr,air_year"`
+	Version    int        `jsonapi:"" gorm:"not null;default:1"`
+	stats      *stats.Stats
+}
+
+type Seasons []*Season
+
+type SeasonResource struct{}
+
+func (Season) TableName() string {
+	return "seasons"
+}
+
+func (s Season) GetID() int {
+	return s.ID
+}
+
+func (s Season) GetVersion() int {
+	return s.Version
+}
+
+func (s *Season) SetVersion(version int) {
+	s.Version = version
+}
+
+func (Season) Relationships() []datastore.Relationship {
+	return []datastore.Relationship{
+		{Name: "show", Type: "shows", ForeignKey: "ShowID"},
+	}
+}
+
+// Meta returns the statistics of the episodes of the season, if they were loaded
+func (s Season) Meta() map[string]interface{} {
+	if s.stats == nil {
+		return nil
+	}
+	return s.stats.Meta()
+}
+
+// loadStats computes the statistics of the episodes of the given seasons
+func loadStats(seasons ...*Season) *herr.Error {
+	ids := make([]int, len(seasons), len(seasons))
+	for i, s := range seasons {
+		ids[i] = s.ShowID
+	}
+	computed, err := stats.FetchSeasons(ids)
+	if err != nil {
+		return err
+	}
+	for _, s := range seasons {
+		seasonStats := computed[stats.Key{ShowID: s.ShowID, Season: s.Number}]
+		s.stats = &seasonStats
+	}
+	return nil
+}
+
+// checkDuplicate ensures that no other season of the show has the same number
+func checkDuplicate(s *Season) *herr.Error {
+	var count int
+	if err := datastore.CountEntities(&Season{}, &count, datastore.Where("show_id = ? AND number = ? AND id <> ?", s.ShowID, s.Number, s.ID)); err != nil {
+		return err
+	}
+	if count > 0 {
+		return &herr.Error{
+			ID:     "duplicate-entry",
+			Status: "409",
+			Title:  "Duplicate Entry",
+			Detail: "The show already has a season with this number",
+			Source: herr.ErrorSource{
+				Pointer: "/data/attributes/number",
+			},
+		}
+	}
+	return nil
+}
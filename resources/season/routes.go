@@ -0,0 +1,248 @@
+package season
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/base"
+	"github.com/torrent-viewer/backend/responses"
+)
+
+// SeasonsList is the HTTP endpoint used to list Seasons instances
+func (SeasonResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Seasons
+	var page requests.Pagination
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Season{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if pagination, err := requests.Paginate(&Season{}, r, trashed); err != nil {
+		responses.SendError(w, *err)
+		return
+	} else {
+		page = pagination
+	}
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, trashed, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := loadStats(entries...); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// SeasonsStore is the HTTP endpoint used to create new Seasons instances
+func (SeasonResource) RouteStore(w http.ResponseWriter, r *http.Request) {
+	var season Season
+	if err := requests.ReceiveEntity(r, &season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateEntity(&season); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := checkDuplicate(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.StoreEntity(&season, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/seasons/%d", season.ID))
+	if err := loadStats(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &season, http.StatusCreated)
+}
+
+// SeasonsView is the HTTP endpoint used to show Seasons instance by ID
+func (SeasonResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var season Season
+	if err := datastore.FetchEntity(&season, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := loadStats(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if requests.NotModified(r, &season) {
+		responses.SendNotModified(w, &season)
+		return
+	}
+	if err := loadStats(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &season, http.StatusOK)
+}
+
+// SeasonsUpdate is the HTTP endpoint used to update some attributes of a Season instance by its ID
+func (SeasonResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var season Season
+	if err := datastore.FetchEntity(&season, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &season)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateFields(&season, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := checkDuplicate(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if season.ID != id {
+		responses.SendError(w, herr.UnmatchingIDsError)
+		return
+	}
+	if err := datastore.UpdateEntity(&season, fields, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := loadStats(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &season, http.StatusOK)
+}
+
+// SeasonsDestroy is the HTTP endpoint used to delete a Season instance by its ID
+func (SeasonResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var season Season
+	if err := datastore.FetchEntity(&season, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&season, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// SeasonsRestore is the HTTP endpoint used to restore a soft-deleted Season instance by its ID
+func (SeasonResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	season := Season{
+		ID: id,
+	}
+	if err := datastore.RestoreEntity(&season, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&season, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := loadStats(&season); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &season, http.StatusOK)
+}
+
+// SeasonsPurge is the HTTP endpoint used to permanently delete a Season instance by its ID
+func (SeasonResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	season := Season{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&season, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Relationships returns the names of the relationships of Season instances
+func (SeasonResource) Relationships() []string {
+	return datastore.RelationshipNames(Season{})
+}
+
+// SeasonsViewRelated is the HTTP endpoint used to show the resources related to a Season instance
+func (SeasonResource) RouteViewRelated(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelated(w, r, &Season{})
+}
+
+// SeasonsViewRelationship is the HTTP endpoint used to show the linkage of a Season relationship
+func (SeasonResource) RouteViewRelationship(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelationship(w, r, &Season{})
+}
+
+// SeasonsUpdateRelationship is the HTTP endpoint used to replace the linkage of a Season relationship
+func (SeasonResource) RouteUpdateRelationship(w http.ResponseWriter, r *http.Request) {
+	base.UpdateRelationship(w, r, &Season{})
+}
+
+// SeasonsAddRelationship is the HTTP endpoint used to add links to a Season relationship
+func (SeasonResource) RouteAddRelationship(w http.ResponseWriter, r *http.Request) {
+	base.AddRelationship(w, r, &Season{})
+}
+
+// SeasonsRemoveRelationship is the HTTP endpoint used to remove links from a Season relationship
+func (SeasonResource) RouteRemoveRelationship(w http.ResponseWriter, r *http.Request) {
+	base.RemoveRelationship(w, r, &Season{})
+}
@@ -0,0 +1,117 @@
+package season
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/episode"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/resources/torrent"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-season-test.db")
+	datastore.Conn.AutoMigrate(&show.Show{}, &Season{}, &episode.Episode{}, &torrent.Torrent{})
+	datastore.Register(&show.Show{}, &Season{}, &episode.Episode{}, &torrent.Torrent{})
+	r := router.NewRouter()
+	r.AddResource("shows", show.ShowResource{})
+	r.AddResource("seasons", SeasonResource{})
+	r.AddResource("episodes", episode.EpisodeResource{})
+	server = httptest.NewServer(r)
+	baseURL = server.URL
+	ret := m.Run()
+	datastore.Conn.DropTable(&show.Show{}, &Season{}, &episode.Episode{}, &torrent.Torrent{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+type document struct {
+	Data struct {
+		ID   string `json:"id"`
+		Meta struct {
+			EpisodesCount int    `json:"episodes_count"`
+			LatestAirDate string `json:"latest_air_date"`
+		} `json:"meta"`
+	} `json:"data"`
+}
+
+func store(t *testing.T, resource string, input string) document {
+	response := testEndpoint(t, "POST", fmt.Sprintf("%s/%s", baseURL, resource), &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var d document
+	if err := json.NewDecoder(response.Body).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func view(t *testing.T, resource string, id string) document {
+	response := testEndpoint(t, "GET", fmt.Sprintf("%s/%s/%s", baseURL, resource, id), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var d document
+	if err := json.NewDecoder(response.Body).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestSeasonsStats(t *testing.T) {
+	s := store(t, "shows", `{"data": {"type": "shows", "attributes": {"title": "The Wire", "year": 2002}}}`)
+	input := fmt.Sprintf(`{"data": {"type": "seasons", "attributes": {"show_id": %s, "number": 1, "air_year": 2002}}}`, s.Data.ID)
+	first := store(t, "seasons", input)
+	response := testEndpoint(t, "POST", fmt.Sprintf("%s/seasons", baseURL), &input)
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusConflict, response.StatusCode)
+	}
+	second := store(t, "seasons", fmt.Sprintf(`{"data": {"type": "seasons", "attributes": {"show_id": %s, "number": 2}}}`, s.Data.ID))
+	for i, airDate := range []int64{1022976000, 1023580800} {
+		store(t, "episodes", fmt.Sprintf(`{"data": {"type": "episodes", "attributes": {"show_id": %s, "season": 1, "number": %d, "air_date": %d}}}`, s.Data.ID, i+1, airDate))
+	}
+	store(t, "episodes", fmt.Sprintf(`{"data": {"type": "episodes", "attributes": {"show_id": %s, "season": 2, "number": 1}}}`, s.Data.ID))
+
+	if d := view(t, "seasons", first.Data.ID); d.Data.Meta.EpisodesCount != 2 || d.Data.Meta.LatestAirDate == "" {
+		t.Errorf("Expected 2 aired episodes in season 1, got %+v", d.Data.Meta)
+	}
+	if d := view(t, "seasons", second.Data.ID); d.Data.Meta.EpisodesCount != 1 || d.Data.Meta.LatestAirDate != "" {
+		t.Errorf("Expected 1 unaired episode in season 2, got %+v", d.Data.Meta)
+	}
+	if d := view(t, "shows", s.Data.ID); d.Data.Meta.EpisodesCount != 3 {
+		t.Errorf("Expected 3 episodes in the show, got %+v", d.Data.Meta)
+	}
+}
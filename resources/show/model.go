@@ -2,17 +2,26 @@ package show
 
 import (
 	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/resources/stats"
 )
 
 type Show struct {
-	ID        int        `jsonapi:"primary,shows" gorm:"primary_key"`
-	CreatedAt time.Time  `jsonapi:"attr,created_at"`
-	UpdatedAt time.Time  `jsonapi:"attr,updated_at"`
-	DeletedAt *time.Time `jsonapi:"" sql:"index"`
-	Title     string     `jsonapi:"attr,title" valid:"ascii,required"`
-	Year      int64      `jsonapi:"attr,year" valid:"required"`
-	// EpisodesCount uint             `json:"episodes-count"`
-	// Episodes      episode.Episodes `json:"episodes"`
+	ID               int        `jsonapi:"primary,shows" gorm:"primary_key"`
+	CreatedAt        time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt        time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt        *time.Time `jsonapi:"" sql:"index"`
+	Title            string     `jsonapi:"attr,title" valid:"ascii,required"`
+	Year             int64      `jsonapi:"attr,year" valid:"required"`
+	ExternalID       string     `jsonapi:"attr,external_id" sql:"index"`
+	Continuing       bool       `jsonapi:"attr,continuing" sql:"index"`
+	QualityProfileID int        `jsonapi:"attr,quality_profile_id" sql:"index"`
+	SyncedAt         *time.Time `jsonapi:""`
+	SyncError        string     `jsonapi:""`
+	Version          int        `jsonapi:"" gorm:"not null;default:1"`
+	stats            *stats.Stats
 }
 
 type Shows []*Show
@@ -25,4 +34,55 @@ func (Show) TableName() string {
 
 func (s Show) GetID() int {
 	return s.ID;
-}
\ No newline at end of file
+}
+
+func (s Show) GetVersion() int {
+	return s.Version
+}
+
+func (s *Show) SetVersion(version int) {
+	s.Version = version
+}
+
+func (Show) Relationships() []datastore.Relationship {
+	return []datastore.Relationship{
+		{Name: "episodes", Type: "episodes", ForeignKey: "ShowID", ToMany: true},
+		{Name: "seasons", Type: "seasons", ForeignKey: "ShowID", ToMany: true},
+		{Name: "quality-profile", Type: "quality-profiles", ForeignKey: "QualityProfileID", Optional: true},
+	}
+}
+
+// Meta returns the statistics of the episodes of the show, if they were loaded,
+// and the outcome of its last metadata synchronization
+func (s Show) Meta() map[string]interface{} {
+	meta := make(map[string]interface{})
+	if s.stats != nil {
+		meta = s.stats.Meta()
+	}
+	if s.SyncedAt != nil {
+		meta["synced_at"] = s.SyncedAt
+		meta["sync_error"] = s.SyncError
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// LoadStats computes the statistics of the episodes of the given shows,
+// which are part of their meta information
+func LoadStats(shows ...*Show) *herr.Error {
+	ids := make([]int, len(shows), len(shows))
+	for i, s := range shows {
+		ids[i] = s.ID
+	}
+	seasons, err := stats.FetchSeasons(ids)
+	if err != nil {
+		return err
+	}
+	for _, s := range shows {
+		showStats := seasons.Show(s.ID)
+		s.stats = &showStats
+	}
+	return nil
+}
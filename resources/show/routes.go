@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/base"
 	"github.com/torrent-viewer/backend/responses"
 	"github.com/torrent-viewer/backend/requests"
 	"github.com/torrent-viewer/backend/herr"
@@ -14,13 +15,27 @@ import (
 func (ShowResource) RouteList(w http.ResponseWriter, r *http.Request) {
 	var entries Shows
 	var page requests.Pagination
-	if pagination, err := requests.Paginate(&Show{}, r); err != nil {
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Show{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if pagination, err := requests.Paginate(&Show{}, r, trashed); err != nil {
 		responses.SendError(w, *err)
 		return	
 	} else {
 		page = pagination
 	}
-	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset); err != nil {
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, trashed, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := LoadStats(entries...); err != nil {
 		responses.SendError(w, *err)
 		return
 	}
@@ -38,11 +53,23 @@ func (ShowResource) RouteStore(w http.ResponseWriter, r *http.Request) {
 		responses.SendError(w, *err)
 		return
 	}
-	if err := datastore.StoreEntity(&show); err != nil {
+	if errs := requests.ValidateEntity(&show); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.StoreEntity(&show, requests.Actor(r)); err != nil {
 		responses.SendError(w, *err)
 		return
 	}
 	w.Header().Set("Location", fmt.Sprintf("/shows/%d", show.ID))
+	if err := LoadStats(&show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
 	responses.SendEntity(w, &show, http.StatusCreated)
 }
 
@@ -58,10 +85,18 @@ func (ShowResource) RouteView(w http.ResponseWriter, r *http.Request) {
 		responses.SendError(w, *err)
 		return
 	}
+	if err := LoadStats(&show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if requests.NotModified(r, &show) {
+		responses.SendNotModified(w, &show)
+		return
+	}
 	responses.SendEntity(w, &show, http.StatusOK)
 }
 
-// ShowsUpdate is the HTTP endpoint used to update a Show instance by its ID
+// ShowsUpdate is the HTTP endpoint used to update some attributes of a Show instance by its ID
 func (ShowResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
 	id, err := requests.ParseID(r)
 	if err != nil {
@@ -73,7 +108,20 @@ func (ShowResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
 		responses.SendError(w, *err)
 		return
 	}
-	if err := requests.ReceiveEntity(r, &show); err != nil {
+	if err := requests.CheckIfMatch(r, &show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &show)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateFields(&show, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&show); err != nil {
 		responses.SendError(w, *err)
 		return
 	}
@@ -81,15 +129,42 @@ func (ShowResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
 		responses.SendError(w, herr.UnmatchingIDsError)
 		return
 	}
-	if err := datastore.UpdateEntity(&show); err != nil {
+	if err := datastore.UpdateEntity(&show, fields, requests.Actor(r)); err != nil {
 		responses.SendError(w, *err)
 		return
 	}
-	responses.SendNoContent(w)
+	if err := LoadStats(&show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &show, http.StatusOK)
 }
 
 // ShowsDestroy is the HTTP endpoint used to delete a Show instance by its ID
 func (ShowResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var show Show
+	if err := datastore.FetchEntity(&show, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&show, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// ShowsRestore is the HTTP endpoint used to restore a soft-deleted Show instance by its ID
+func (ShowResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
 	id, err := requests.ParseID(r)
 	if err != nil {
 		responses.SendError(w, *err)
@@ -98,9 +173,64 @@ func (ShowResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
 	show := Show{
 		ID: id,
 	}
-	if err := datastore.DeleteEntity(&show); err != nil {
+	if err := datastore.RestoreEntity(&show, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&show, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := LoadStats(&show); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &show, http.StatusOK)
+}
+
+// ShowsPurge is the HTTP endpoint used to permanently delete a Show instance by its ID
+func (ShowResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	show := Show{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&show, requests.Actor(r)); err != nil {
 		responses.SendError(w, *err)
 		return
 	}
 	responses.SendNoContent(w)
 }
+
+// Relationships returns the names of the relationships of Show instances
+func (ShowResource) Relationships() []string {
+	return datastore.RelationshipNames(Show{})
+}
+
+// ShowsViewRelated is the HTTP endpoint used to show the resources related to a Show instance
+func (ShowResource) RouteViewRelated(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelated(w, r, &Show{})
+}
+
+// ShowsViewRelationship is the HTTP endpoint used to show the linkage of a Show relationship
+func (ShowResource) RouteViewRelationship(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelationship(w, r, &Show{})
+}
+
+// ShowsUpdateRelationship is the HTTP endpoint used to replace the linkage of a Show relationship
+func (ShowResource) RouteUpdateRelationship(w http.ResponseWriter, r *http.Request) {
+	base.UpdateRelationship(w, r, &Show{})
+}
+
+// ShowsAddRelationship is the HTTP endpoint used to add links to a Show relationship
+func (ShowResource) RouteAddRelationship(w http.ResponseWriter, r *http.Request) {
+	base.AddRelationship(w, r, &Show{})
+}
+
+// ShowsRemoveRelationship is the HTTP endpoint used to remove links from a Show relationship
+func (ShowResource) RouteRemoveRelationship(w http.ResponseWriter, r *http.Request) {
+	base.RemoveRelationship(w, r, &Show{})
+}
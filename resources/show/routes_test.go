@@ -2,6 +2,7 @@ package show
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,11 +12,14 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	// Initialize SQLite driver
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/shwoodard/jsonapi"
 	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/resources/torrent"
 	"github.com/torrent-viewer/backend/router"
 )
 
@@ -25,16 +29,34 @@ var (
 	integerOverflow string = "9223372036854775808"
 )
 
+// episode holds the columns of the episodes table from which the statistics
+// of shows are computed, as the episode package depends on the show package
+type episode struct {
+	ID      int `jsonapi:"primary,episodes" gorm:"primary_key"`
+	ShowID  int
+	Season  int
+	AirDate *time.Time
+}
+
+func (episode) TableName() string {
+	return "episodes"
+}
+
+func (e episode) GetID() int {
+	return e.ID
+}
+
 func TestMain(m *testing.M) {
 	flag.Parse()
 	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-test.db")
-	datastore.Conn.AutoMigrate(&Show{})
+	datastore.Conn.AutoMigrate(&Show{}, &episode{}, &torrent.Torrent{})
+	datastore.Register(&Show{}, &episode{}, &torrent.Torrent{})
 	r := router.NewRouter()
 	r.AddResource("shows", ShowResource{})
 	server = httptest.NewServer(r)
 	baseURL = fmt.Sprintf("%s/shows", server.URL)
 	ret := m.Run()
-	datastore.Conn.DropTable(&Show{})
+	datastore.Conn.DropTable(&Show{}, &episode{}, &torrent.Torrent{})
 	os.Exit(ret)
 }
 
@@ -92,8 +114,21 @@ func TestShowsStore(t *testing.T) {
     }
   }`
 	response = testEndpoint(t, "POST", baseURL, &input)
-	if response.StatusCode != http.StatusBadRequest {
-		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	var errors struct {
+		Errors herr.Errors `json:"errors"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&errors); err != nil {
+		t.Error(err)
+	} else if len(errors.Errors) != 1 {
+		t.Errorf("Expected 1 error, got %d", len(errors.Errors))
+	} else {
+		e := errors.Errors[0]
+		if e.Source.Pointer != "/data/attributes/title" || e.Code != "required" {
+			t.Errorf("Expected a required error on /data/attributes/title, got %s on %s", e.Code, e.Source.Pointer)
+		}
 	}
 	input = `{
     "data": {
@@ -188,8 +223,62 @@ func TestShowsUpdate(t *testing.T) {
 		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
 	}
 	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, show.ID), &input)
-	if response.StatusCode != http.StatusNoContent {
-		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	input = fmt.Sprintf(`{
+    "data": {
+      "type": "shows",
+      "id": "%d",
+      "attributes": {
+        "title": null
+      }
+    }
+  }`, show.ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, show.ID), &input)
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	input = fmt.Sprintf(`{
+    "data": {
+      "type": "shows",
+      "id": "%d",
+      "attributes": {
+        "year": 1984
+      }
+    }
+  }`, show.ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, show.ID), &input)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var updated Show
+	if err := jsonapi.UnmarshalPayload(response.Body, &updated); err != nil {
+		t.Error(err)
+	} else if updated.Title != "GhostBusters" || updated.Year != 1984 {
+		t.Errorf("Expected GhostBusters (1984), got %s (%d)", updated.Title, updated.Year)
+	}
+	// Read-only attributes are ignored
+	input = fmt.Sprintf(`{
+    "data": {
+      "type": "shows",
+      "id": "%d",
+      "attributes": {
+        "year": 1985,
+        "created_at": 0,
+        "updated_at": 0
+      }
+    }
+  }`, show.ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, show.ID), &input)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var stored Show
+	if err := datastore.FetchEntity(&stored, show.ID); err != nil {
+		t.Error(err)
+	} else if stored.Year != 1985 || stored.CreatedAt.Unix() != show.CreatedAt.Unix() || stored.UpdatedAt.Unix() == 0 {
+		t.Errorf("Expected 1985 and unchanged timestamps, got %d, created at %s and updated at %s", stored.Year, stored.CreatedAt, stored.UpdatedAt)
 	}
 	show.ID += 1000
 	buf = new(bytes.Buffer)
@@ -236,3 +325,172 @@ func TestShowsDestroy(t *testing.T) {
 		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
 	}
 }
+
+func TestShowsRestore(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "shows",
+      "attributes": {
+        "title": "Star Wars VII",
+        "year": 2015
+      }
+    }
+  }`
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var show Show
+	if err := jsonapi.UnmarshalPayload(response.Body, &show); err != nil {
+		t.Error(err)
+		return
+	}
+	response = testEndpoint(t, "POST", fmt.Sprintf("%s/%d/restore", baseURL, show.ID), nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+	response = testEndpoint(t, "DELETE", fmt.Sprintf("%s/%d", baseURL, show.ID), nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s?filter[trashed]=forever", baseURL), nil)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s?filter[trashed]=only", baseURL), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var trashed struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&trashed); err != nil {
+		t.Error(err)
+	} else if len(trashed.Data) == 0 || trashed.Data[len(trashed.Data)-1].ID != fmt.Sprintf("%d", show.ID) {
+		t.Errorf("Expected show %d to be listed in the trash", show.ID)
+	}
+	response = testEndpoint(t, "POST", fmt.Sprintf("%s/%d/restore", baseURL, show.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s/%d", baseURL, show.ID), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	response = testEndpoint(t, "DELETE", fmt.Sprintf("%s/%d/purge", baseURL, show.ID), nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "POST", fmt.Sprintf("%s/%d/restore", baseURL, show.ID), nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+}
+
+func TestShowsConditional(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "shows",
+      "attributes": {
+        "title": "Star Wars VII",
+        "year": 2015
+      }
+    }
+  }`
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	etag := response.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+	var show Show
+	if err := jsonapi.UnmarshalPayload(response.Body, &show); err != nil {
+		t.Error(err)
+		return
+	}
+	url := fmt.Sprintf("%s/%d", baseURL, show.ID)
+	request, _ := http.NewRequest("GET", url, nil)
+	request.Header.Set("If-None-Match", etag)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotModified, response.StatusCode)
+	}
+	// The ETag of a compressed response matches the same version
+	compressed, _ := http.NewRequest("GET", url, nil)
+	compressed.Header.Set("If-None-Match", router.EncodedETag(etag, "gzip"))
+	response, err = http.DefaultClient.Do(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotModified, response.StatusCode)
+	}
+	// The statistics of the show change without a new version of the show
+	if err := datastore.Conn.Create(&episode{ShowID: show.ID, Season: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if response.Header.Get("ETag") == etag {
+		t.Errorf("Expected the ETag to change with the statistics of the show")
+	}
+	show.Title = "GhostBusters"
+	buf := new(bytes.Buffer)
+	if err := jsonapi.MarshalOnePayload(buf, &show); err != nil {
+		t.Error(err)
+		return
+	}
+	input = buf.String()
+	// If-Match uses the strong comparison
+	request, _ = http.NewRequest("PATCH", url, strings.NewReader(input))
+	request.Header.Set("If-Match", "W/"+etag)
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected HTTP %d for a weak ETag, got HTTP %d", http.StatusPreconditionFailed, response.StatusCode)
+	}
+	request, _ = http.NewRequest("PATCH", url, strings.NewReader(input))
+	request.Header.Set("If-Match", etag)
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	if response.Header.Get("ETag") == etag {
+		t.Errorf("Expected the ETag to change after an update")
+	}
+	request, _ = http.NewRequest("PATCH", url, strings.NewReader(input))
+	request.Header.Set("If-Match", etag)
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusPreconditionFailed, response.StatusCode)
+	}
+	request, _ = http.NewRequest("DELETE", url, nil)
+	request.Header.Set("If-Match", etag)
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusPreconditionFailed, response.StatusCode)
+	}
+}
@@ -0,0 +1,98 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+)
+
+// Stats are the figures computed from the episodes of a show or a season
+type Stats struct {
+	EpisodesCount        int
+	EpisodesWithTorrents int
+	LatestAirDate        *time.Time
+}
+
+// Key identifies the episodes of a season of a show
+type Key struct {
+	ShowID int
+	Season int
+}
+
+// Seasons maps seasons to the Stats of their episodes
+type Seasons map[Key]Stats
+
+// Meta returns the Stats as the meta information of a resource
+func (s Stats) Meta() map[string]interface{} {
+	return map[string]interface{}{
+		"episodes_count":         s.EpisodesCount,
+		"episodes_with_torrents": s.EpisodesWithTorrents,
+		"latest_air_date":        s.LatestAirDate,
+	}
+}
+
+func (s Stats) add(other Stats) Stats {
+	s.EpisodesCount += other.EpisodesCount
+	s.EpisodesWithTorrents += other.EpisodesWithTorrents
+	airDate := other.LatestAirDate
+	if airDate != nil && (s.LatestAirDate == nil || airDate.After(*s.LatestAirDate)) {
+		s.LatestAirDate = airDate
+	}
+	return s
+}
+
+// FetchSeasons computes the Stats of each season of the given shows.
+// The episodes and torrents models must be registered in the datastore.
+func FetchSeasons(showIDs []int) (Seasons, *herr.Error) {
+	seasons := make(Seasons)
+	if len(showIDs) == 0 {
+		return seasons, nil
+	}
+	episodes, torrents := datastore.NewEntity("episodes"), datastore.NewEntity("torrents")
+	if episodes == nil || torrents == nil {
+		return nil, &herr.Error{
+			ID:     "unknown-type",
+			Status: "500",
+			Title:  "Unknown resource type",
+			Detail: "The episodes and torrents models must be registered to compute statistics",
+		}
+	}
+	var rows []struct {
+		ShowID      int
+		Season      int
+		AirDate     *time.Time
+		HasTorrents bool
+	}
+	hasTorrents := fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %[1]s.episode_id = %s.id AND %[1]s.deleted_at IS NULL) AS has_torrents",
+		datastore.Conn.NewScope(torrents).TableName(), datastore.Conn.NewScope(episodes).TableName())
+	if err := datastore.Conn.Model(episodes).Select("show_id, season, air_date, "+hasTorrents).Where("show_id IN (?)", showIDs).Scan(&rows).Error; err != nil {
+		return nil, &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	for _, e := range rows {
+		key := Key{ShowID: e.ShowID, Season: e.Season}
+		episode := Stats{EpisodesCount: 1, LatestAirDate: e.AirDate}
+		if e.HasTorrents {
+			episode.EpisodesWithTorrents = 1
+		}
+		seasons[key] = seasons[key].add(episode)
+	}
+	return seasons, nil
+}
+
+// Show returns the Stats of all the seasons of a show
+func (seasons Seasons) Show(showID int) Stats {
+	var s Stats
+	for key, season := range seasons {
+		if key.ShowID == showID {
+			s = s.add(season)
+		}
+	}
+	return s
+}
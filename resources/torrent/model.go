@@ -0,0 +1,328 @@
+package torrent
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+)
+
+// Torrent is a release of an episode.
+// Link is the magnet URI or URL of the torrent, and Trackers are the
+// comma-separated announce URLs of its trackers.
+// An EpisodeID of 0 means that the torrent was not matched to an episode.
+// The health of the torrent is updated by scraping its trackers.
+// A torrent sent to a download client records the client, and the state and
+// progress of the download, from 0 to 1, as reported by the client.
+type Torrent struct {
+	ID               int        `jsonapi:"primary,torrents" gorm:"primary_key"`
+	CreatedAt        time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt        time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt        *time.Time `jsonapi:"" sql:"index"`
+	EpisodeID        int        `jsonapi:"attr,episode_id" sql:"index"`
+	Name             string     `jsonapi:"attr,name" valid:"required"`
+	InfoHash         string     `jsonapi:"attr,info_hash" valid:"hexadecimal,stringlength(40|40),required" gorm:"unique_index"`
+	Link             string     `jsonapi:"attr,link"`
+	Size             int64      `jsonapi:"attr,size"`
+	Trackers         string     `jsonapi:"attr,trackers" sql:"type:text"`
+	Seeders          int        `jsonapi:""`
+	Leechers         int        `jsonapi:""`
+	Completed        int        `jsonapi:""`
+	ScrapedAt        *time.Time `jsonapi:""`
+	DownloadClientID int        `jsonapi:"" sql:"index"`
+	DownloadState    string     `jsonapi:""`
+	DownloadProgress float64    `jsonapi:""`
+	Version          int        `jsonapi:"" gorm:"not null;default:1"`
+}
+
+type Torrents []*Torrent
+
+type TorrentResource struct{}
+
+func (Torrent) TableName() string {
+	return "torrents"
+}
+
+func (t Torrent) GetID() int {
+	return t.ID
+}
+
+func (t Torrent) GetVersion() int {
+	return t.Version
+}
+
+func (t *Torrent) SetVersion(version int) {
+	t.Version = version
+}
+
+func (Torrent) Relationships() []datastore.Relationship {
+	return []datastore.Relationship{
+		{Name: "episode", Type: "episodes", ForeignKey: "EpisodeID", Optional: true},
+	}
+}
+
+// Meta returns the health of the torrent, if its trackers were scraped,
+// and the state of its download, if it was sent to a download client
+func (t Torrent) Meta() map[string]interface{} {
+	meta := make(map[string]interface{})
+	if t.ScrapedAt != nil {
+		meta["seeders"] = t.Seeders
+		meta["leechers"] = t.Leechers
+		meta["completed"] = t.Completed
+		meta["scraped_at"] = t.ScrapedAt
+	}
+	if t.DownloadClientID != 0 {
+		meta["download_client_id"] = t.DownloadClientID
+		meta["download_state"] = t.DownloadState
+		meta["download_progress"] = t.DownloadProgress
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// Magnet returns the link of the torrent if it is a magnet URI, or a magnet
+// URI built from its info hash, name and trackers otherwise
+func (t Torrent) Magnet() string {
+	if strings.HasPrefix(t.Link, "magnet:") {
+		return t.Link
+	}
+	values := url.Values{}
+	values.Set("dn", t.Name)
+	for _, tracker := range t.TrackerList() {
+		values.Add("tr", tracker)
+	}
+	return "magnet:?xt=urn:btih:" + t.InfoHash + "&" + values.Encode()
+}
+
+// RecordDownload stores the download client a torrent was sent to and the
+// state and progress of its download, without creating a new version of
+// the torrent.
+// The change is published as a "progressed" mutation of the torrent,
+// followed by a "completed" one once the download is complete.
+func RecordDownload(t *Torrent, clientID int, state string, progress float64) *herr.Error {
+	completed := t.DownloadProgress < 1 && progress >= 1
+	t.DownloadClientID = clientID
+	t.DownloadState = state
+	t.DownloadProgress = progress
+	columns := map[string]interface{}{
+		"download_client_id": clientID,
+		"download_state":     state,
+		"download_progress":  progress,
+	}
+	if err := datastore.Conn.Model(t).UpdateColumns(columns).Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	signal("progressed", t)
+	if completed {
+		signal("completed", t)
+	}
+	return nil
+}
+
+// Match publishes a "matched" mutation of the torrents matched to an
+// episode, when they are created with an episode or when their episode
+// changes.
+// It is meant to be registered with datastore.OnMutation.
+func Match(m datastore.Mutation) {
+	if m.Type != datastore.ResourceType(Torrent{}) || m.Action != "create" && m.Action != "update" {
+		return
+	}
+	episode, _ := m.After["episode_id"].(int)
+	if previous, _ := m.Before["episode_id"].(int); episode == 0 || episode == previous {
+		return
+	}
+	datastore.Publish(datastore.Mutation{
+		Action: "matched",
+		Type:   m.Type,
+		ID:     m.ID,
+		After:  m.After,
+		Actor:  m.Actor,
+		Time:   m.Time,
+	})
+}
+
+// RecordHealth stores the health of the torrents of the given info hash,
+// without creating a new version of them.
+// The change is published as a "scraped" mutation of each torrent.
+func RecordHealth(infoHash string, seeders int, leechers int, completed int, at time.Time) *herr.Error {
+	columns := map[string]interface{}{
+		"seeders":    seeders,
+		"leechers":   leechers,
+		"completed":  completed,
+		"scraped_at": at,
+	}
+	if err := datastore.Conn.Model(&Torrent{}).Where("info_hash = ?", infoHash).UpdateColumns(columns).Error; err != nil {
+		return &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	var torrents Torrents
+	if err := datastore.FetchEntities(&torrents, "info_hash = ?", infoHash); err != nil {
+		return err
+	}
+	for _, t := range torrents {
+		signal("scraped", t)
+	}
+	return nil
+}
+
+// signal publishes a change of the state of a torrent, which is not part of
+// its attributes, as a mutation whose attributes include the meta of the
+// torrent, and the show of its episode so that the followers of the show
+// are notified
+func signal(action string, t *Torrent) {
+	attributes := datastore.Attributes(t)
+	for name, value := range t.Meta() {
+		attributes[name] = value
+	}
+	if t.EpisodeID != 0 {
+		var shows []int
+		if err := datastore.Conn.Table("episodes").Where("id = ?", t.EpisodeID).Pluck("show_id", &shows).Error; err == nil && len(shows) == 1 {
+			attributes["show_id"] = shows[0]
+		}
+	}
+	datastore.Publish(datastore.Mutation{
+		Action: action,
+		Type:   datastore.ResourceType(t),
+		ID:     t.ID,
+		After:  attributes,
+	})
+}
+
+// TrackerList returns the announce URLs of the trackers of the torrent
+func (t Torrent) TrackerList() []string {
+	trackers := []string{}
+	for _, tracker := range strings.Split(t.Trackers, ",") {
+		if tracker = strings.TrimSpace(tracker); tracker != "" {
+			trackers = append(trackers, tracker)
+		}
+	}
+	return trackers
+}
+
+// normalize lowers the case of the info hash, so that hashes can be compared
+func normalize(t *Torrent) {
+	t.InfoHash = strings.ToLower(t.InfoHash)
+}
+
+// findByInfoHash returns the torrent of the given info hash, even if it was
+// soft-deleted, or nil if there is none.
+// Torrents stored before info hashes were unique may share one, in which case
+// the oldest torrent not in the trash is returned.
+func findByInfoHash(infoHash string) (*Torrent, *herr.Error) {
+	var torrents Torrents
+	if err := datastore.FetchPagedEntities(&torrents, 1, 0, datastore.WithTrashed, datastore.Where("info_hash = ?", infoHash), datastore.Order("deleted_at IS NOT NULL, id")); err != nil {
+		return nil, err
+	}
+	if len(torrents) == 0 {
+		return nil, nil
+	}
+	return torrents[0], nil
+}
+
+// merge adds the trackers of from to the trackers of into, and fills the
+// metadata into lacks with the metadata of from.
+// It returns the names of the fields of into that were changed.
+func merge(into *Torrent, from Torrent) []string {
+	fields := []string{}
+	trackers := into.TrackerList()
+	known := make(map[string]bool)
+	for _, tracker := range trackers {
+		known[tracker] = true
+	}
+	for _, tracker := range from.TrackerList() {
+		if !known[tracker] {
+			known[tracker] = true
+			trackers = append(trackers, tracker)
+		}
+	}
+	if len(trackers) > len(into.TrackerList()) {
+		into.Trackers = strings.Join(trackers, ",")
+		fields = append(fields, "Trackers")
+	}
+	if into.Link == "" && from.Link != "" {
+		into.Link = from.Link
+		fields = append(fields, "Link")
+	}
+	if into.Size == 0 && from.Size != 0 {
+		into.Size = from.Size
+		fields = append(fields, "Size")
+	}
+	if into.EpisodeID == 0 && from.EpisodeID != 0 {
+		into.EpisodeID = from.EpisodeID
+		fields = append(fields, "EpisodeID")
+	}
+	return fields
+}
+
+// Deduplicate merges the torrents sharing an info hash into the oldest of
+// them not in the trash, and purges the others. The unique index of info
+// hashes is then created, if the duplicates prevented it.
+// It returns the torrents other torrents were merged into.
+func Deduplicate(by datastore.Actor) (Torrents, *herr.Error) {
+	var torrents Torrents
+	// A limit and offset of -1 fetch every torrent
+	if err := datastore.FetchPagedEntities(&torrents, -1, -1, datastore.WithTrashed); err != nil {
+		return nil, err
+	}
+	sort.Slice(torrents, func(i, j int) bool {
+		if (torrents[i].DeletedAt == nil) != (torrents[j].DeletedAt == nil) {
+			return torrents[i].DeletedAt == nil
+		}
+		return torrents[i].ID < torrents[j].ID
+	})
+	oldest := make(map[string]*Torrent)
+	merged := Torrents{}
+	for _, t := range torrents {
+		into, ok := oldest[t.InfoHash]
+		if !ok {
+			oldest[t.InfoHash] = t
+			continue
+		}
+		// Trashed torrents are purged without being merged into one another
+		if into.DeletedAt == nil {
+			if fields := merge(into, *t); len(fields) > 0 {
+				if err := datastore.UpdateEntity(into, fields, by); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := datastore.PurgeEntity(t, by); err != nil {
+			return nil, err
+		}
+		if !contains(merged, into) {
+			merged = append(merged, into)
+		}
+	}
+	if err := datastore.Conn.AutoMigrate(&Torrent{}).Error; err != nil {
+		return nil, &herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: err.Error(),
+		}
+	}
+	return merged, nil
+}
+
+func contains(torrents Torrents, t *Torrent) bool {
+	for _, e := range torrents {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
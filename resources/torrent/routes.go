@@ -0,0 +1,347 @@
+package torrent
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/base"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var filters = map[string]string{
+	"episode_id": "episode_id",
+	"info_hash":  "info_hash",
+}
+
+// TorrentsList is the HTTP endpoint used to list Torrents instances
+func (TorrentResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Torrents
+	var page requests.Pagination
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Torrent{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	scopes := append([]datastore.Scope{trashed}, requests.ParseFilters(r, filters)...)
+	if pagination, err := requests.Paginate(&Torrent{}, r, scopes...); err != nil {
+		responses.SendError(w, *err)
+		return
+	} else {
+		page = pagination
+	}
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, append(scopes, sort)...); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// TorrentsStore is the HTTP endpoint used to create new Torrents instances
+func (TorrentResource) RouteStore(w http.ResponseWriter, r *http.Request) {
+	var torrent Torrent
+	if err := requests.ReceiveEntity(r, &torrent); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	normalize(&torrent)
+	if errs := requests.ValidateEntity(&torrent); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&torrent); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	merging, err := parseMerge(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	existing, err := findByInfoHash(torrent.InfoHash)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if existing == nil {
+		err := datastore.StoreEntity(&torrent, requests.Actor(r))
+		if err == nil {
+			w.Header().Set("Location", fmt.Sprintf("/torrents/%d", torrent.ID))
+			responses.SendEntity(w, &torrent, http.StatusCreated)
+			return
+		}
+		// The info hash may have been stored by a concurrent request
+		if err.Status != "409" {
+			responses.SendError(w, *err)
+			return
+		}
+		if existing, err = findByInfoHash(torrent.InfoHash); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		if existing == nil {
+			responses.SendError(w, herr.ConflictingEntryError)
+			return
+		}
+	}
+	if !merging || existing.DeletedAt != nil {
+		responses.SendError(w, duplicateError(existing))
+		return
+	}
+	if fields := merge(existing, torrent); len(fields) > 0 {
+		if err := datastore.UpdateEntity(existing, fields, requests.Actor(r)); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+	}
+	w.Header().Set("Location", fmt.Sprintf("/torrents/%d", existing.ID))
+	responses.SendEntity(w, existing, http.StatusOK)
+}
+
+// duplicateError reports that a torrent has the info hash of existing
+func duplicateError(existing *Torrent) herr.Error {
+	duplicate := herr.DuplicateEntryError
+	duplicate.Detail = "A torrent with the same info hash already exists"
+	if existing.DeletedAt != nil {
+		duplicate.Detail = "A torrent with the same info hash is in the trash"
+	}
+	duplicate.Source = herr.ErrorSource{
+		Pointer: "/data/attributes/info_hash",
+	}
+	duplicate.Links.About = fmt.Sprintf("/torrents/%d", existing.ID)
+	return duplicate
+}
+
+// parseMerge parses the merge query parameter, telling whether a torrent
+// whose info hash is already known is merged into the existing torrent
+func parseMerge(r *http.Request) (bool, *herr.Error) {
+	switch r.URL.Query().Get("merge") {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	return false, &herr.Error{
+		ID:     "invalid-parameter",
+		Status: "400",
+		Title:  "Invalid query parameter",
+		Detail: "merge must be either \"true\" or \"false\"",
+		Source: herr.ErrorSource{
+			Parameter: "merge",
+		},
+	}
+}
+
+// TorrentsView is the HTTP endpoint used to show Torrents instance by ID
+func (TorrentResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var torrent Torrent
+	if err := datastore.FetchEntity(&torrent, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if requests.NotModified(r, &torrent) {
+		responses.SendNotModified(w, &torrent)
+		return
+	}
+	responses.SendEntity(w, &torrent, http.StatusOK)
+}
+
+// TorrentsUpdate is the HTTP endpoint used to update some attributes of a Torrent instance by its ID
+func (TorrentResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var torrent Torrent
+	if err := datastore.FetchEntity(&torrent, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &torrent); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &torrent)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	normalize(&torrent)
+	if errs := requests.ValidateFields(&torrent, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.CheckLinks(&torrent); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if torrent.ID != id {
+		responses.SendError(w, herr.UnmatchingIDsError)
+		return
+	}
+	if err := checkInfoHash(&torrent); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.UpdateEntity(&torrent, fields, requests.Actor(r)); err != nil {
+		// The info hash may have been stored by a concurrent request
+		if err.Status == "409" {
+			if dupErr := checkInfoHash(&torrent); dupErr != nil {
+				err = dupErr
+			}
+		}
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &torrent, http.StatusOK)
+}
+
+// checkInfoHash ensures that no other torrent has the info hash of t
+func checkInfoHash(t *Torrent) *herr.Error {
+	existing, err := findByInfoHash(t.InfoHash)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != t.ID {
+		duplicate := duplicateError(existing)
+		return &duplicate
+	}
+	return nil
+}
+
+// TorrentsDestroy is the HTTP endpoint used to delete a Torrent instance by its ID
+func (TorrentResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var torrent Torrent
+	if err := datastore.FetchEntity(&torrent, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &torrent); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&torrent, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// TorrentsRestore is the HTTP endpoint used to restore a soft-deleted Torrent instance by its ID
+func (TorrentResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	torrent := Torrent{
+		ID: id,
+	}
+	if err := datastore.RestoreEntity(&torrent, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&torrent, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, &torrent, http.StatusOK)
+}
+
+// TorrentsPurge is the HTTP endpoint used to permanently delete a Torrent instance by its ID
+func (TorrentResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	torrent := Torrent{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&torrent, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Relationships returns the names of the relationships of Torrent instances
+func (TorrentResource) Relationships() []string {
+	return datastore.RelationshipNames(Torrent{})
+}
+
+// TorrentsViewRelated is the HTTP endpoint used to show the resources related to a Torrent instance
+func (TorrentResource) RouteViewRelated(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelated(w, r, &Torrent{})
+}
+
+// TorrentsViewRelationship is the HTTP endpoint used to show the linkage of a Torrent relationship
+func (TorrentResource) RouteViewRelationship(w http.ResponseWriter, r *http.Request) {
+	base.ViewRelationship(w, r, &Torrent{})
+}
+
+// TorrentsUpdateRelationship is the HTTP endpoint used to replace the linkage of a Torrent relationship
+func (TorrentResource) RouteUpdateRelationship(w http.ResponseWriter, r *http.Request) {
+	base.UpdateRelationship(w, r, &Torrent{})
+}
+
+// TorrentsAddRelationship is the HTTP endpoint used to add links to a Torrent relationship
+func (TorrentResource) RouteAddRelationship(w http.ResponseWriter, r *http.Request) {
+	base.AddRelationship(w, r, &Torrent{})
+}
+
+// TorrentsRemoveRelationship is the HTTP endpoint used to remove links from a Torrent relationship
+func (TorrentResource) RouteRemoveRelationship(w http.ResponseWriter, r *http.Request) {
+	base.RemoveRelationship(w, r, &Torrent{})
+}
+
+// Routes returns the routes of the torrents that are not part of the
+// resource itself
+func Routes() router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/torrents/deduplicate",
+			Handler: MergeDuplicates,
+			Method:  "POST",
+			Name:    "torrents.deduplicate",
+		},
+	}
+}
+
+// MergeDuplicates is the HTTP endpoint used to merge the torrents sharing an
+// info hash, and list the torrents others were merged into
+func MergeDuplicates(w http.ResponseWriter, r *http.Request) {
+	merged, err := Deduplicate(requests.Actor(r))
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(merged), len(merged))
+	for i, t := range merged {
+		serialized[i] = t
+	}
+	responses.SendEntities(w, serialized)
+}
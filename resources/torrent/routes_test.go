@@ -0,0 +1,293 @@
+package torrent
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/shwoodard/jsonapi"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+	// mutations are the mutations published during the tests
+	mutations []datastore.Mutation
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-torrent-test.db")
+	datastore.Conn.AutoMigrate(&Torrent{})
+	datastore.Register(&Torrent{})
+	datastore.OnMutation(func(m datastore.Mutation) {
+		mutations = append(mutations, m)
+	})
+	r := router.NewRouter()
+	r.AddResource("torrents", TorrentResource{})
+	r.AddRoutes(Routes())
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/torrents", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&Torrent{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestTorrentsStore(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "torrents",
+      "attributes": {
+        "name": "Breaking.Bad.S01E01.720p.HDTV.x264-CTU",
+        "info_hash": "not-a-hash"
+      }
+    }
+  }`
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	input = `{
+    "data": {
+      "type": "torrents",
+      "attributes": {
+        "name": "Breaking.Bad.S01E01.720p.HDTV.x264-CTU",
+        "info_hash": "C12FE1C06BBA254A9DC9F519B335AA7C1367A88A",
+        "trackers": "udp://tracker.example.com:80, http://tracker.example.org/announce"
+      }
+    }
+  }`
+	response = testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var torrent Torrent
+	if err := jsonapi.UnmarshalPayload(response.Body, &torrent); err != nil {
+		t.Fatal(err)
+	}
+	if torrent.InfoHash != "c12fe1c06bba254a9dc9f519b335aa7c1367a88a" {
+		t.Errorf("Expected the info hash to be lower case, got %s", torrent.InfoHash)
+	}
+	if trackers := torrent.TrackerList(); len(trackers) != 2 || trackers[1] != "http://tracker.example.org/announce" {
+		t.Errorf("Unexpected trackers %v", trackers)
+	}
+
+	response = testEndpoint(t, "GET", fmt.Sprintf("%s?filter[info_hash]=%s", baseURL, torrent.InfoHash), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+}
+
+func TestTorrentsDuplicates(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "torrents",
+      "attributes": {
+        "name": "The.Wire.S01E01.720p.HDTV.x264-CTU",
+        "info_hash": "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678",
+        "trackers": "udp://tracker.example.com:80"
+      }
+    }
+  }`
+	response := testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var original Torrent
+	if err := jsonapi.UnmarshalPayload(response.Body, &original); err != nil {
+		t.Fatal(err)
+	}
+
+	input = `{
+    "data": {
+      "type": "torrents",
+      "attributes": {
+        "name": "the wire s01e01 720p",
+        "info_hash": "A1B2C3D4E5F60718293A4B5C6D7E8F9012345678",
+        "link": "magnet:?xt=urn:btih:a1b2c3d4e5f60718293a4b5c6d7e8f9012345678",
+        "trackers": "udp://tracker.example.com:80,http://tracker.example.org/announce"
+      }
+    }
+  }`
+	response = testEndpoint(t, "POST", baseURL, &input)
+	if response.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusConflict, response.StatusCode)
+	}
+	var errors struct {
+		Errors []struct {
+			Links struct {
+				About string `json:"about"`
+			} `json:"links"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&errors); err != nil {
+		t.Fatal(err)
+	}
+	if about := fmt.Sprintf("/torrents/%d", original.ID); len(errors.Errors) != 1 || errors.Errors[0].Links.About != about {
+		t.Errorf("Expected a link to %s, got %+v", about, errors)
+	}
+
+	response = testEndpoint(t, "POST", baseURL+"?merge=yes", &input)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusBadRequest, response.StatusCode)
+	}
+	response = testEndpoint(t, "POST", baseURL+"?merge=true", &input)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var merged Torrent
+	if err := jsonapi.UnmarshalPayload(response.Body, &merged); err != nil {
+		t.Fatal(err)
+	}
+	if merged.ID != original.ID || merged.Name != original.Name || merged.Link == "" || len(merged.TrackerList()) != 2 {
+		t.Errorf("Expected the torrent to be merged into torrent %d, got %+v", original.ID, merged)
+	}
+
+	// Duplicates stored before info hashes were unique
+	if err := datastore.Conn.Model(&Torrent{}).RemoveIndex("uix_torrents_info_hash").Error; err != nil {
+		t.Fatal(err)
+	}
+	duplicates := []Torrent{
+		{Name: "Pilot", InfoHash: "00000000000000000000000000000000000000aa", Trackers: "udp://a.example.com:80"},
+		{Name: "Pilot", InfoHash: "00000000000000000000000000000000000000aa", Trackers: "udp://b.example.com:80", Size: 1024},
+		{Name: "Pilot", InfoHash: "00000000000000000000000000000000000000aa", Trackers: "udp://a.example.com:80"},
+	}
+	for i := range duplicates {
+		if err := datastore.StoreEntity(&duplicates[i], datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	response = testEndpoint(t, "POST", baseURL+"/deduplicate", nil)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var kept Torrent
+	if err := datastore.FetchEntity(&kept, duplicates[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if kept.Size != 1024 || kept.Trackers != "udp://a.example.com:80,udp://b.example.com:80" {
+		t.Errorf("Expected the duplicates to be merged, got %+v", kept)
+	}
+	for _, d := range duplicates[1:] {
+		if err := datastore.FetchEntity(&Torrent{}, d.ID); err == nil || err.Status != "404" {
+			t.Errorf("Expected duplicate %d to be deleted, got %v", d.ID, err)
+		}
+	}
+	duplicate := Torrent{Name: "Pilot", InfoHash: "00000000000000000000000000000000000000aa"}
+	if err := datastore.StoreEntity(&duplicate, datastore.Actor{}); err == nil || err.Status != "409" {
+		t.Errorf("Expected info hashes to be unique once merged, got %v", err)
+	}
+}
+
+func TestTorrentsConcurrentDuplicates(t *testing.T) {
+	input := `{
+    "data": {
+      "type": "torrents",
+      "attributes": {
+        "name": "Deadwood.S01E01.720p",
+        "info_hash": "d00dd00dd00dd00dd00dd00dd00dd00dd00dd00d"
+      }
+    }
+  }`
+	statuses := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(statuses); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := http.Post(baseURL, "application/vnd.api+json", strings.NewReader(input))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			response.Body.Close()
+			statuses <- response.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	created := 0
+	for status := range statuses {
+		if status == http.StatusCreated {
+			created++
+		} else if status != http.StatusConflict {
+			t.Errorf("Expected HTTP %d or %d, got HTTP %d", http.StatusCreated, http.StatusConflict, status)
+		}
+	}
+	var count int
+	if err := datastore.CountEntities(&Torrent{}, &count, datastore.Where("info_hash = ?", "d00dd00dd00dd00dd00dd00dd00dd00dd00dd00d")); err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 || count != 1 {
+		t.Errorf("Expected a single torrent to be created, got %d created and %d stored", created, count)
+	}
+}
+
+func TestTorrentsUpdateDuplicate(t *testing.T) {
+	torrents := []Torrent{
+		{Name: "Rome.S01E01", InfoHash: "0000000000000000000000000000000000000bb1"},
+		{Name: "Rome.S01E02", InfoHash: "0000000000000000000000000000000000000bb2"},
+	}
+	for i := range torrents {
+		if err := datastore.StoreEntity(&torrents[i], datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := fmt.Sprintf(`{"data": {"type": "torrents", "id": "%d", "attributes": {"info_hash": "0000000000000000000000000000000000000BB1"}}}`, torrents[1].ID)
+	response := testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, torrents[1].ID), &input)
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusConflict, response.StatusCode)
+	}
+	// A torrent in the trash keeps its info hash
+	if err := datastore.DeleteEntity(&torrents[0], datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, torrents[1].ID), &input)
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusConflict, response.StatusCode)
+	}
+	input = fmt.Sprintf(`{"data": {"type": "torrents", "id": "%d", "attributes": {"name": "Rome.S01E02.720p", "info_hash": "0000000000000000000000000000000000000bb2"}}}`, torrents[1].ID)
+	response = testEndpoint(t, "PATCH", fmt.Sprintf("%s/%d", baseURL, torrents[1].ID), &input)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	mutations = nil
+	Match(datastore.Mutation{Action: "create", Type: "torrents", ID: 1, After: map[string]interface{}{"episode_id": 3}})
+	Match(datastore.Mutation{Action: "create", Type: "torrents", ID: 2, After: map[string]interface{}{"episode_id": 0}})
+	Match(datastore.Mutation{Action: "update", Type: "torrents", ID: 1, Before: map[string]interface{}{"episode_id": 3}, After: map[string]interface{}{"episode_id": 3}})
+	Match(datastore.Mutation{Action: "update", Type: "torrents", ID: 2, Before: map[string]interface{}{"episode_id": 0}, After: map[string]interface{}{"episode_id": 4}})
+	Match(datastore.Mutation{Action: "update", Type: "episodes", ID: 4, Before: map[string]interface{}{}, After: map[string]interface{}{"episode_id": 4}})
+	if len(mutations) != 2 || mutations[0].Action != "matched" || mutations[0].ID != 1 || mutations[1].ID != 2 {
+		t.Errorf("Expected torrents 1 and 2 to be matched, got %v", mutations)
+	}
+}
@@ -0,0 +1,43 @@
+package watch
+
+import (
+	"time"
+)
+
+// Entry is a show on the watchlist of a user.
+// A show is on the watchlist of a user at most once.
+type Entry struct {
+	ID        int       `jsonapi:"primary,watchlist-entries" gorm:"primary_key"`
+	CreatedAt time.Time `jsonapi:"attr,created_at"`
+	Principal string    `jsonapi:"attr,principal" gorm:"unique_index:idx_watchlist_entries_principal_show"`
+	ShowID    int       `jsonapi:"attr,show_id" sql:"index" gorm:"unique_index:idx_watchlist_entries_principal_show"`
+}
+
+type Entries []*Entry
+
+// Mark records that a user watched an episode.
+// An episode is marked at most once per user.
+type Mark struct {
+	ID        int       `jsonapi:"primary,watched-marks" gorm:"primary_key"`
+	CreatedAt time.Time `jsonapi:"attr,watched_at"`
+	Principal string    `jsonapi:"attr,principal" gorm:"unique_index:idx_watched_marks_principal_episode"`
+	EpisodeID int       `jsonapi:"attr,episode_id" sql:"index" gorm:"unique_index:idx_watched_marks_principal_episode"`
+}
+
+type Marks []*Mark
+
+func (Entry) TableName() string {
+	return "watchlist_entries"
+}
+
+func (e Entry) GetID() int {
+	return e.ID
+}
+
+func (Mark) TableName() string {
+	return "watched_marks"
+}
+
+func (m Mark) GetID() int {
+	return m.ID
+}
@@ -0,0 +1,284 @@
+package watch
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+// Routes returns the routes used by users to manage their watchlist and
+// watched episodes. They must be protected by a Firewall.
+func Routes() router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/me/watchlist",
+			Handler: ListWatchlist,
+			Method:  "GET",
+			Name:    "me.watchlist.list",
+		},
+		router.Route{
+			Path:    "/me/watchlist",
+			Handler: AddToWatchlist,
+			Method:  "POST",
+			Name:    "me.watchlist.add",
+		},
+		router.Route{
+			Path:    "/me/watchlist",
+			Handler: RemoveFromWatchlist,
+			Method:  "DELETE",
+			Name:    "me.watchlist.remove",
+		},
+		router.Route{
+			Path:    "/me/episodes/{id:[0-9]+}/watched",
+			Handler: ViewWatched,
+			Method:  "GET",
+			Name:    "me.watched.view",
+		},
+		router.Route{
+			Path:    "/me/episodes/{id:[0-9]+}/watched",
+			Handler: MarkWatched,
+			Method:  "PUT",
+			Name:    "me.watched.mark",
+		},
+		router.Route{
+			Path:    "/me/episodes/{id:[0-9]+}/watched",
+			Handler: UnmarkWatched,
+			Method:  "DELETE",
+			Name:    "me.watched.unmark",
+		},
+	}
+}
+
+// principal returns the user authenticated for the current Request
+func principal(r *http.Request) (string, *herr.Error) {
+	p := router.Principal(r)
+	if p == "" {
+		return "", &herr.UnauthorizedError
+	}
+	return p, nil
+}
+
+// ParseWatched parses the filter[watched] query parameter into the scopes
+// restricting episodes to those watched, or not watched, by the current user
+func ParseWatched(r *http.Request) ([]datastore.Scope, *herr.Error) {
+	value := r.URL.Query().Get("filter[watched]")
+	if value == "" {
+		return []datastore.Scope{}, nil
+	}
+	p, err := principal(r)
+	if err != nil {
+		return nil, err
+	}
+	watched, parseErr := strconv.ParseBool(value)
+	if parseErr != nil {
+		return nil, &herr.Error{
+			ID:     "invalid-parameter",
+			Status: "400",
+			Title:  "Invalid query parameter",
+			Detail: "filter[watched] must be either \"true\" or \"false\"",
+			Source: herr.ErrorSource{
+				Parameter: "filter[watched]",
+			},
+		}
+	}
+	query := "id IN (SELECT episode_id FROM watched_marks WHERE principal = ?)"
+	if watched == false {
+		query = "id NOT IN (SELECT episode_id FROM watched_marks WHERE principal = ?)"
+	}
+	return []datastore.Scope{datastore.Where(query, p)}, nil
+}
+
+// ListWatchlist is the HTTP endpoint used to list the Shows on the watchlist of the current user
+func ListWatchlist(w http.ResponseWriter, r *http.Request) {
+	p, err := principal(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &show.Show{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	listed := datastore.Where("id IN (SELECT show_id FROM watchlist_entries WHERE principal = ?)", p)
+	page, err := requests.Paginate(&show.Show{}, r, listed)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var entries show.Shows
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, listed, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
+
+// AddToWatchlist is the HTTP endpoint used to add Shows to the watchlist of the current user
+func AddToWatchlist(w http.ResponseWriter, r *http.Request) {
+	p, err := principal(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	ids, err := requests.ReceiveLinkage(r, "shows", true)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	for _, id := range ids {
+		var s show.Show
+		if err := datastore.FetchEntity(&s, id); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		var count int
+		if err := datastore.CountEntities(&Entry{}, &count, datastore.Where("principal = ? AND show_id = ?", p, id)); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+		if count > 0 {
+			continue
+		}
+		entry := Entry{
+			Principal: p,
+			ShowID:    id,
+		}
+		if err := datastore.StoreEntity(&entry, requests.Actor(r)); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+	}
+	responses.SendNoContent(w)
+}
+
+// RemoveFromWatchlist is the HTTP endpoint used to remove Shows from the watchlist of the current user
+func RemoveFromWatchlist(w http.ResponseWriter, r *http.Request) {
+	p, err := principal(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	ids, err := requests.ReceiveLinkage(r, "shows", true)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if len(ids) == 0 {
+		responses.SendNoContent(w)
+		return
+	}
+	var entries Entries
+	if err := datastore.FetchEntities(&entries, "principal = ? AND show_id IN (?)", p, ids); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	for _, entry := range entries {
+		if err := datastore.DeleteEntity(entry, requests.Actor(r)); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+	}
+	responses.SendNoContent(w)
+}
+
+// fetchMark returns the mark of the episode identified in the URL
+// by the current user, or nil if the user did not watch it
+func fetchMark(r *http.Request) (int, *Mark, *herr.Error) {
+	p, err := principal(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	id, err := requests.ParseID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var marks Marks
+	if err := datastore.FetchEntities(&marks, "principal = ? AND episode_id = ?", p, id); err != nil {
+		return 0, nil, err
+	}
+	if len(marks) == 0 {
+		return id, nil, nil
+	}
+	return id, marks[0], nil
+}
+
+// ViewWatched is the HTTP endpoint used to show when the current user watched an Episode
+func ViewWatched(w http.ResponseWriter, r *http.Request) {
+	_, mark, err := fetchMark(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if mark == nil {
+		responses.SendError(w, herr.Error{
+			ID:     "not-found",
+			Status: "404",
+			Title:  "Not Found",
+			Detail: "The episode has not been watched.",
+		})
+		return
+	}
+	responses.SendEntity(w, mark, http.StatusOK)
+}
+
+// MarkWatched is the HTTP endpoint used to mark an Episode as watched by the current user
+func MarkWatched(w http.ResponseWriter, r *http.Request) {
+	id, mark, err := fetchMark(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if mark != nil {
+		responses.SendEntity(w, mark, http.StatusOK)
+		return
+	}
+	episode := datastore.NewEntity("episodes")
+	if episode == nil {
+		responses.SendError(w, herr.Error{
+			ID:     "unknown-type",
+			Status: "500",
+			Title:  "Unknown resource type",
+		})
+		return
+	}
+	if err := datastore.FetchEntity(episode, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	mark = &Mark{
+		Principal: router.Principal(r),
+		EpisodeID: id,
+	}
+	if err := datastore.StoreEntity(mark, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendEntity(w, mark, http.StatusCreated)
+}
+
+// UnmarkWatched is the HTTP endpoint used to mark an Episode as not watched by the current user
+func UnmarkWatched(w http.ResponseWriter, r *http.Request) {
+	_, mark, err := fetchMark(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if mark != nil {
+		if err := datastore.DeleteEntity(mark, requests.Actor(r)); err != nil {
+			responses.SendError(w, *err)
+			return
+		}
+	}
+	responses.SendNoContent(w)
+}
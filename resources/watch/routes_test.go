@@ -0,0 +1,190 @@
+package watch
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/show"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+)
+
+// episode is a fixture standing for the episodes resource,
+// which depends on this package
+type episode struct {
+	ID     int `jsonapi:"primary,episodes" gorm:"primary_key"`
+	Number int `jsonapi:"attr,number"`
+}
+
+func (episode) TableName() string {
+	return "episodes"
+}
+
+func (e episode) GetID() int {
+	return e.ID
+}
+
+// guard authenticates users by the Username header
+func guard(r *http.Request) (string, bool) {
+	username := r.Header.Get("Username")
+	return username, username != ""
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-watch-test.db")
+	datastore.Conn.AutoMigrate(&show.Show{}, &episode{}, &Entry{}, &Mark{})
+	datastore.Register(&show.Show{}, &episode{})
+	r := router.NewRouter()
+	r.Use(router.FirewallMiddleware(router.FirewallConfig{
+		Guard: guard,
+		Only:  []string{"^/me/"},
+	}))
+	r.AddRoutes(Routes())
+	server = httptest.NewServer(r)
+	baseURL = server.URL
+	ret := m.Run()
+	datastore.Conn.DropTable(&show.Show{}, &episode{}, &Entry{}, &Mark{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, username string, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "" {
+		request.Header.Set("Username", username)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func ids(t *testing.T, response *http.Response) []string {
+	var document struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, resource := range document.Data {
+		ids = append(ids, resource.ID)
+	}
+	return ids
+}
+
+func TestWatchlist(t *testing.T) {
+	first := show.Show{Title: "The Wire", Year: 2002}
+	second := show.Show{Title: "Treme", Year: 2010}
+	for _, s := range []*show.Show{&first, &second} {
+		if err := datastore.StoreEntity(s, datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	url := fmt.Sprintf("%s/me/watchlist", baseURL)
+
+	response := testEndpoint(t, "", "GET", url, nil)
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnauthorized, response.StatusCode)
+	}
+	input := fmt.Sprintf(`{"data": [{"type": "shows", "id": "%d"}, {"type": "shows", "id": "%d"}]}`, first.ID, second.ID)
+	response = testEndpoint(t, "alice", "POST", url, &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	input = fmt.Sprintf(`{"data": [{"type": "shows", "id": "%d"}]}`, first.ID)
+	response = testEndpoint(t, "alice", "DELETE", url, &input)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "alice", "GET", url, nil)
+	if listed := ids(t, response); len(listed) != 1 || listed[0] != fmt.Sprintf("%d", second.ID) {
+		t.Errorf("Expected watchlist to contain show %d, got %v", second.ID, listed)
+	}
+	response = testEndpoint(t, "bob", "GET", url, nil)
+	if listed := ids(t, response); len(listed) != 0 {
+		t.Errorf("Expected an empty watchlist, got %v", listed)
+	}
+}
+
+func TestWatched(t *testing.T) {
+	e := episode{Number: 1}
+	if err := datastore.StoreEntity(&e, datastore.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/me/episodes/%d/watched", baseURL, e.ID)
+
+	response := testEndpoint(t, "alice", "GET", url, nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+	response = testEndpoint(t, "alice", "PUT", url, nil)
+	if response.StatusCode != http.StatusCreated {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	response = testEndpoint(t, "alice", "PUT", url, nil)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	response = testEndpoint(t, "alice", "PUT", fmt.Sprintf("%s/me/episodes/2147483647/watched", baseURL), nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+
+	response = testEndpoint(t, "bob", "GET", url, nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+	response = testEndpoint(t, "alice", "DELETE", url, nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, response.StatusCode)
+	}
+	response = testEndpoint(t, "alice", "GET", url, nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusNotFound, response.StatusCode)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	entries := []interface{}{
+		&Entry{Principal: "alice", ShowID: 1},
+		&Entry{Principal: "bob", ShowID: 1},
+		&Entry{Principal: "alice", ShowID: 1},
+		&Mark{Principal: "alice", EpisodeID: 1},
+		&Mark{Principal: "bob", EpisodeID: 1},
+		&Mark{Principal: "alice", EpisodeID: 1},
+	}
+	for i, entry := range entries {
+		err := datastore.StoreEntity(entry, datastore.Actor{})
+		if i%3 < 2 && err != nil {
+			t.Errorf("Expected %+v to be stored, got %v", entry, err)
+		}
+		if i%3 == 2 && (err == nil || err.Status != "409") {
+			t.Errorf("Expected %+v to conflict, got %v", entry, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/events"
+	"github.com/torrent-viewer/backend/jobs"
+)
+
+// DeliveryJob is the type of the jobs attempting webhook deliveries
+const DeliveryJob = "webhooks.deliver"
+
+// maxAttempts is the number of attempts after which a delivery fails
+const maxAttempts = 8
+
+// retryDelay is the delay before the second attempt of a delivery, which
+// doubles at each following attempt
+var retryDelay = 30 * time.Second
+
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+type deliveryPayload struct {
+	DeliveryID int `json:"delivery_id"`
+}
+
+// Payload is the JSON document posted to webhooks
+type Payload struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// Enqueue schedules the delivery of the event describing the given mutation
+// to the webhooks subscribing to it. Mutations of entities belonging to a
+// principal, such as watchlist entries, are not sent.
+// It is meant to be registered with datastore.OnMutation.
+func Enqueue(m datastore.Mutation) {
+	name, principal, change := events.Describe(m)
+	if principal != "" {
+		return
+	}
+	var webhooks Webhooks
+	if err := datastore.Conn.Find(&webhooks).Error; err != nil {
+		log.Println("Could not load webhooks:", err)
+		return
+	}
+	for _, w := range webhooks {
+		if !w.Subscribed(name) {
+			continue
+		}
+		delivery, err := enqueue(w.ID, name, change)
+		if err == nil {
+			err = schedule(delivery, time.Now())
+		}
+		if err != nil {
+			log.Println("Could not enqueue webhook delivery:", err)
+		}
+	}
+}
+
+// enqueue stores a pending delivery of the given event.
+// Deliveries are stored directly, so that they are not mutations themselves.
+func enqueue(webhookID int, event string, data interface{}) (*Delivery, error) {
+	payload, err := json.Marshal(Payload{Event: event, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	delivery := Delivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       string(payload),
+		Status:        StatusPending,
+		NextAttemptAt: &now,
+	}
+	if err := datastore.Conn.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// schedule queues a DeliveryJob attempting the delivery at the given time
+func schedule(delivery *Delivery, at time.Time) error {
+	_, err := jobs.EnqueueAt(DeliveryJob, deliveryPayload{DeliveryID: delivery.ID}, at)
+	return err
+}
+
+// DeliveryHandler is the jobs.Handler attempting the delivery of a
+// DeliveryJob.
+// Each attempt is a job of its own, scheduled by the previous one, so that
+// deliveries keep their own number of attempts and backoff in their log.
+// Only the failures to record the outcome of an attempt are retried by the
+// job itself.
+func DeliveryHandler(payload []byte) error {
+	var p deliveryPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	var delivery Delivery
+	if err := datastore.Conn.First(&delivery, p.DeliveryID).Error; err != nil {
+		return err
+	}
+	if delivery.Status != StatusPending {
+		return nil
+	}
+	return attempt(&delivery)
+}
+
+// attempt sends the delivery to its webhook, records the outcome, and
+// schedules the next attempt if the delivery failed
+func attempt(delivery *Delivery) error {
+	delivery.Attempts++
+	var w Webhook
+	var status int
+	var err error
+	if dberr := datastore.Conn.First(&w, delivery.WebhookID).Error; dberr != nil {
+		err = fmt.Errorf("webhook %d is gone", delivery.WebhookID)
+		delivery.Attempts = maxAttempts
+	} else {
+		status, err = send(w, delivery)
+	}
+
+	now := time.Now()
+	delivery.ResponseStatus = status
+	if err == nil {
+		delivery.Status = StatusDelivered
+		delivery.Error = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	} else if delivery.Attempts >= maxAttempts {
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = nil
+	} else {
+		next := now.Add(retryDelay << uint(delivery.Attempts-1))
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+	err = datastore.Conn.Model(delivery).UpdateColumns(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_status": delivery.ResponseStatus,
+		"error":           delivery.Error,
+		"delivered_at":    delivery.DeliveredAt,
+		"next_attempt_at": delivery.NextAttemptAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if delivery.NextAttemptAt != nil {
+		return schedule(delivery, *delivery.NextAttemptAt)
+	}
+	return nil
+}
+
+// send posts the payload of the delivery to the webhook, signed with its
+// secret, and returns the HTTP status of the response
+func send(w Webhook, delivery *Delivery) (int, error) {
+	request, err := http.NewRequest("POST", w.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "torrent-viewer-webhooks")
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+	request.Header.Set("X-Webhook-Signature", Sign(w.Secret, []byte(delivery.Payload)))
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected HTTP %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// Sign returns the signature of a payload sent in the X-Webhook-Signature
+// header: "sha256=" followed by the hexadecimal HMAC-SHA256 of the payload,
+// keyed with the secret of the webhook
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is an URL notified of the events it subscribes to.
+// Events is a comma-separated list of event names, such as
+// "episodes.created", where "shows.*" matches every event of the shows and
+// "*" matches every event.
+// Besides the changes of the resources, "torrents.matched" is sent when a
+// torrent is matched to an episode and "torrents.completed" when its
+// download completes.
+// Its secret signs the payloads, it can be written but is never sent back.
+type Webhook struct {
+	ID        int        `jsonapi:"primary,webhooks" gorm:"primary_key"`
+	CreatedAt time.Time  `jsonapi:"attr,created_at"`
+	UpdatedAt time.Time  `jsonapi:"attr,updated_at"`
+	DeletedAt *time.Time `jsonapi:"" sql:"index"`
+	Name      string     `jsonapi:"attr,name" valid:"required"`
+	URL       string     `jsonapi:"attr,url" valid:"url,required"`
+	Events    string     `jsonapi:"attr,events" valid:"required"`
+	Secret    string     `jsonapi:"attr,secret,omitempty" secret:"true" valid:"required"`
+	Version   int        `jsonapi:"" gorm:"not null;default:1"`
+}
+
+type Webhooks []*Webhook
+
+type WebhookResource struct{}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+func (w Webhook) GetID() int {
+	return w.ID
+}
+
+func (w Webhook) GetVersion() int {
+	return w.Version
+}
+
+func (w *Webhook) SetVersion(version int) {
+	w.Version = version
+}
+
+// Subscribed reports whether the webhook subscribes to the given event
+func (w Webhook) Subscribed(event string) bool {
+	for _, pattern := range strings.Split(w.Events, ",") {
+		pattern = strings.TrimSpace(pattern)
+		switch {
+		case pattern == "*" || pattern == event:
+			return true
+		case strings.HasSuffix(pattern, ".*") && strings.HasPrefix(event, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// redact removes the secret of the given webhooks before they are sent
+func redact(webhooks ...*Webhook) {
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+}
+
+// States of a Delivery
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Delivery is an event sent, or to be sent, to a Webhook.
+// Pending deliveries are attempted again at NextAttemptAt, by a job.
+type Delivery struct {
+	ID             int        `jsonapi:"primary,webhook-deliveries" gorm:"primary_key"`
+	CreatedAt      time.Time  `jsonapi:"attr,created_at"`
+	WebhookID      int        `jsonapi:"attr,webhook_id" sql:"index"`
+	Event          string     `jsonapi:"attr,event"`
+	Payload        string     `jsonapi:"attr,payload" sql:"type:text"`
+	Status         string     `jsonapi:"attr,status" sql:"index"`
+	Attempts       int        `jsonapi:"attr,attempts"`
+	ResponseStatus int        `jsonapi:"attr,response_status"`
+	Error          string     `jsonapi:"attr,error" sql:"type:text"`
+	NextAttemptAt  *time.Time `jsonapi:"attr,next_attempt_at" sql:"index"`
+	DeliveredAt    *time.Time `jsonapi:"attr,delivered_at"`
+}
+
+type Deliveries []*Delivery
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+func (d Delivery) GetID() int {
+	return d.ID
+}
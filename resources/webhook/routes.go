@@ -0,0 +1,281 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/requests"
+	"github.com/torrent-viewer/backend/responses"
+	"github.com/torrent-viewer/backend/router"
+)
+
+// WebhooksList is the HTTP endpoint used to list Webhooks instances
+func (WebhookResource) RouteList(w http.ResponseWriter, r *http.Request) {
+	var entries Webhooks
+	var page requests.Pagination
+	trashed, err := requests.ParseTrashed(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	sort, err := requests.ParseSort(r, &Webhook{})
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if pagination, err := requests.Paginate(&Webhook{}, r, trashed); err != nil {
+		responses.SendError(w, *err)
+		return
+	} else {
+		page = pagination
+	}
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, trashed, sort); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	redact(entries...)
+	responses.SendEntities(w, serialized)
+}
+
+// WebhooksStore is the HTTP endpoint used to create new Webhooks instances
+func (WebhookResource) RouteStore(w http.ResponseWriter, r *http.Request) {
+	var webhook Webhook
+	if err := requests.ReceiveEntity(r, &webhook); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateEntity(&webhook); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if err := datastore.StoreEntity(&webhook, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/webhooks/%d", webhook.ID))
+	redact(&webhook)
+	responses.SendEntity(w, &webhook, http.StatusCreated)
+}
+
+// WebhooksView is the HTTP endpoint used to show Webhooks instance by ID
+func (WebhookResource) RouteView(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var webhook Webhook
+	if err := datastore.FetchEntity(&webhook, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if requests.NotModified(r, &webhook) {
+		responses.SendNotModified(w, &webhook)
+		return
+	}
+	redact(&webhook)
+	responses.SendEntity(w, &webhook, http.StatusOK)
+}
+
+// WebhooksUpdate is the HTTP endpoint used to update some attributes of a Webhook instance by its ID
+func (WebhookResource) RouteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var webhook Webhook
+	if err := datastore.FetchEntity(&webhook, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &webhook); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	fields, err := requests.ReceivePatch(r, &webhook)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if errs := requests.ValidateFields(&webhook, fields); errs != nil {
+		responses.SendErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	if webhook.ID != id {
+		responses.SendError(w, herr.UnmatchingIDsError)
+		return
+	}
+	if err := datastore.UpdateEntity(&webhook, fields, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	redact(&webhook)
+	responses.SendEntity(w, &webhook, http.StatusOK)
+}
+
+// WebhooksDestroy is the HTTP endpoint used to delete a Webhook instance by its ID
+func (WebhookResource) RouteDestroy(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var webhook Webhook
+	if err := datastore.FetchEntity(&webhook, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := requests.CheckIfMatch(r, &webhook); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.DeleteEntity(&webhook, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// WebhooksRestore is the HTTP endpoint used to restore a soft-deleted Webhook instance by its ID
+func (WebhookResource) RouteRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	webhook := Webhook{
+		ID: id,
+	}
+	if err := datastore.RestoreEntity(&webhook, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	if err := datastore.FetchEntity(&webhook, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	redact(&webhook)
+	responses.SendEntity(w, &webhook, http.StatusOK)
+}
+
+// WebhooksPurge is the HTTP endpoint used to permanently delete a Webhook instance by its ID
+func (WebhookResource) RoutePurge(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	webhook := Webhook{
+		ID: id,
+	}
+	if err := datastore.PurgeEntity(&webhook, requests.Actor(r)); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	responses.SendNoContent(w)
+}
+
+// Routes returns the routes of the webhooks that are not part of the
+// resource itself
+func Routes() router.Routes {
+	return router.Routes{
+		router.Route{
+			Path:    "/webhooks/{id:[0-9]+}/test",
+			Handler: Test,
+			Method:  "POST",
+			Name:    "webhooks.test",
+		},
+		router.Route{
+			Path:    "/webhooks/{id:[0-9]+}/deliveries",
+			Handler: DeliveriesList,
+			Method:  "GET",
+			Name:    "webhooks.deliveries",
+		},
+	}
+}
+
+// Test is the HTTP endpoint used to send a test event to a Webhook instance.
+// The delivery is attempted right away, and retried like any other delivery
+// if it fails.
+func Test(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var webhook Webhook
+	if err := datastore.FetchEntity(&webhook, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	data := map[string]interface{}{
+		"type":  "webhooks",
+		"id":    webhook.ID,
+		"actor": requests.Actor(r).Principal,
+	}
+	delivery, dberr := enqueue(webhook.ID, "webhooks.test", data)
+	if dberr != nil {
+		responses.SendError(w, herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: dberr.Error(),
+		})
+		return
+	}
+	if dberr := attempt(delivery); dberr != nil {
+		responses.SendError(w, herr.Error{
+			ID:     "database-error",
+			Status: "500",
+			Title:  "Database Error",
+			Detail: dberr.Error(),
+		})
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/webhooks/%d/deliveries", webhook.ID))
+	responses.SendEntity(w, delivery, http.StatusCreated)
+}
+
+var deliveryFilters = map[string]string{
+	"status": "status",
+	"event":  "event",
+}
+
+// DeliveriesList is the HTTP endpoint used to list the Delivery instances of
+// a Webhook instance, newest first
+func DeliveriesList(w http.ResponseWriter, r *http.Request) {
+	id, err := requests.ParseID(r)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var webhook Webhook
+	if err := datastore.FetchEntity(&webhook, id); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	var entries Deliveries
+	scopes := append(requests.ParseFilters(r, deliveryFilters), datastore.Where("webhook_id = ?", webhook.ID))
+	page, err := requests.Paginate(&Delivery{}, r, scopes...)
+	if err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	scopes = append(scopes, datastore.Order("id desc"))
+	if err := datastore.FetchPagedEntities(&entries, page.Limit, page.Offset, scopes...); err != nil {
+		responses.SendError(w, *err)
+		return
+	}
+	serialized := make([]interface{}, len(entries), len(entries))
+	for i, e := range entries {
+		serialized[i] = e
+	}
+	responses.SendEntities(w, serialized)
+}
@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/jobs"
+	"github.com/torrent-viewer/backend/router"
+)
+
+var (
+	server  *httptest.Server
+	baseURL string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-webhook-test.db")
+	datastore.Conn.AutoMigrate(&Webhook{}, &Delivery{}, &jobs.Job{})
+	r := router.NewRouter()
+	r.AddResource("webhooks", WebhookResource{})
+	r.AddRoutes(Routes())
+	server = httptest.NewServer(r)
+	baseURL = fmt.Sprintf("%s/webhooks", server.URL)
+	ret := m.Run()
+	datastore.Conn.DropTable(&Webhook{}, &Delivery{}, &jobs.Job{})
+	os.Exit(ret)
+}
+
+func testEndpoint(t *testing.T, method string, url string, input *string) *http.Response {
+	var reader io.Reader
+	if input != nil {
+		reader = strings.NewReader(*input)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// receiver records the requests it receives, and answers them with the
+// given statuses in turn
+type receiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.bodies = append(rc.bodies, string(body))
+	rc.headers = append(rc.headers, r.Header)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// runDeliveries runs the queued delivery jobs through DeliveryHandler, as
+// a worker would, and removes them
+func runDeliveries(t *testing.T) {
+	var queued jobs.Jobs
+	if err := datastore.FetchEntities(&queued, "type = ?", DeliveryJob); err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range queued {
+		if err := datastore.Conn.Delete(job).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := DeliveryHandler([]byte(job.Payload)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func store(t *testing.T, url string, events string) (*http.Response, map[string]interface{}) {
+	input := fmt.Sprintf(`{
+    "data": {
+      "type": "webhooks",
+      "attributes": {
+        "name": "Bot",
+        "url": "%s",
+        "events": "%s",
+        "secret": "secret"
+      }
+    }
+  }`, url, events)
+	response := testEndpoint(t, "POST", baseURL, &input)
+	var document struct {
+		Data struct {
+			ID         string                 `json:"id"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"data"`
+	}
+	if response.StatusCode == http.StatusCreated {
+		if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+			t.Fatal(err)
+		}
+		document.Data.Attributes["id"] = document.Data.ID
+	}
+	return response, document.Data.Attributes
+}
+
+func TestWebhooksStore(t *testing.T) {
+	response, _ := store(t, "not an url", "*")
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusUnprocessableEntity, response.StatusCode)
+	}
+	response, attributes := store(t, "http://localhost:8000/hook", "*")
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	if _, ok := attributes["secret"]; ok {
+		t.Error("Expected the secret not to be sent back")
+	}
+}
+
+func TestSubscribed(t *testing.T) {
+	w := Webhook{Events: "episodes.created, shows.*"}
+	for event, expected := range map[string]bool{
+		"episodes.created": true,
+		"episodes.deleted": false,
+		"shows.updated":    true,
+		"showsx.updated":   false,
+	} {
+		if w.Subscribed(event) != expected {
+			t.Errorf("Expected %q subscribed to be %v", event, expected)
+		}
+	}
+}
+
+func TestWebhooksTest(t *testing.T) {
+	rc := &receiver{}
+	target := httptest.NewServer(rc)
+	defer target.Close()
+	_, attributes := store(t, target.URL, "episodes.created")
+
+	response := testEndpoint(t, "POST", fmt.Sprintf("%s/%s/test", baseURL, attributes["id"]), nil)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusCreated, response.StatusCode)
+	}
+	var document struct {
+		Data struct {
+			Attributes struct {
+				Status string `json:"status"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if document.Data.Attributes.Status != StatusDelivered {
+		t.Errorf("Expected the test event to be delivered, got %s", document.Data.Attributes.Status)
+	}
+	if len(rc.bodies) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(rc.bodies))
+	}
+	if rc.headers[0].Get("X-Webhook-Event") != "webhooks.test" {
+		t.Errorf("Expected a test event, got %s", rc.headers[0].Get("X-Webhook-Event"))
+	}
+	if signature := Sign("secret", []byte(rc.bodies[0])); rc.headers[0].Get("X-Webhook-Signature") != signature {
+		t.Errorf("Expected signature %s, got %s", signature, rc.headers[0].Get("X-Webhook-Signature"))
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	retryDelay = 0
+	rc := &receiver{statuses: []int{http.StatusInternalServerError}}
+	target := httptest.NewServer(rc)
+	defer target.Close()
+	_, attributes := store(t, target.URL, "shows.*")
+
+	Enqueue(datastore.Mutation{Action: "update", Type: "shows", ID: 1, After: map[string]interface{}{"title": "Twin Peaks"}})
+	Enqueue(datastore.Mutation{Action: "update", Type: "episodes", ID: 1})
+	Enqueue(datastore.Mutation{Action: "create", Type: "watchlist-entries", ID: 1, After: map[string]interface{}{"principal": "alice"}})
+	runDeliveries(t)
+	runDeliveries(t)
+
+	response := testEndpoint(t, "GET", fmt.Sprintf("%s/%s/deliveries", baseURL, attributes["id"]), nil)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusOK, response.StatusCode)
+	}
+	var document struct {
+		Data []struct {
+			Attributes struct {
+				Event    string `json:"event"`
+				Status   string `json:"status"`
+				Attempts int    `json:"attempts"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Data) != 1 {
+		t.Fatalf("Expected 1 delivery, got %v", document.Data)
+	}
+	delivery := document.Data[0].Attributes
+	if delivery.Event != "shows.updated" || delivery.Status != StatusDelivered || delivery.Attempts != 2 {
+		t.Errorf("Expected shows.updated to be delivered at the second attempt, got %v", delivery)
+	}
+	if len(rc.bodies) != 2 || !strings.Contains(rc.bodies[1], `"title":"Twin Peaks"`) {
+		t.Errorf("Expected the change to be posted twice, got %v", rc.bodies)
+	}
+}
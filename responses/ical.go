@@ -0,0 +1,76 @@
+package responses
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CalendarEvent is an all-day event of an iCalendar feed
+type CalendarEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	Updated     time.Time
+}
+
+// maxLineLength is the maximum length of a content line in octets,
+// longer lines are folded
+const maxLineLength = 75
+
+var calendarEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\;",
+	",", "\\,",
+	"\r\n", "\\n",
+	"\n", "\\n",
+)
+
+// SendCalendar writes the given events to w as an iCalendar (RFC 5545) feed
+func SendCalendar(w http.ResponseWriter, name string, events []CalendarEvent) error {
+	var buf bytes.Buffer
+	writeCalendarLine(&buf, "BEGIN:VCALENDAR")
+	writeCalendarLine(&buf, "VERSION:2.0")
+	writeCalendarLine(&buf, "PRODID:-//torrent-viewer//backend//EN")
+	writeCalendarLine(&buf, "CALSCALE:GREGORIAN")
+	writeCalendarLine(&buf, "METHOD:PUBLISH")
+	writeCalendarLine(&buf, "X-WR-CALNAME:"+calendarEscaper.Replace(name))
+	for _, e := range events {
+		writeCalendarLine(&buf, "BEGIN:VEVENT")
+		writeCalendarLine(&buf, "UID:"+e.UID)
+		writeCalendarLine(&buf, "DTSTAMP:"+e.Updated.UTC().Format("20060102T150405Z"))
+		writeCalendarLine(&buf, "DTSTART;VALUE=DATE:"+e.Date.Format("20060102"))
+		writeCalendarLine(&buf, "DTEND;VALUE=DATE:"+e.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeCalendarLine(&buf, "SUMMARY:"+calendarEscaper.Replace(e.Summary))
+		if e.Description != "" {
+			writeCalendarLine(&buf, "DESCRIPTION:"+calendarEscaper.Replace(e.Description))
+		}
+		writeCalendarLine(&buf, "END:VEVENT")
+	}
+	writeCalendarLine(&buf, "END:VCALENDAR")
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeCalendarLine writes a content line terminated by CRLF, folding it
+// without splitting UTF-8 characters when it is too long
+func writeCalendarLine(buf *bytes.Buffer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		fmt.Fprintf(buf, "%s\r\n ", line[:cut])
+		line = line[cut:]
+		// Continuation lines start with a space
+		limit = maxLineLength - 1
+	}
+	fmt.Fprintf(buf, "%s\r\n", line)
+}
@@ -0,0 +1,64 @@
+package responses
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/shwoodard/jsonapi"
+)
+
+// Inclusion is a resource related to the primary data of a compound
+// document through its to-one relationship named Relationship.
+// A nil Entity means that the relationship is empty.
+type Inclusion struct {
+	Relationship string
+	Entity       interface{}
+}
+
+// SendCompoundEntity marshalls the given entity, with the linkage of the
+// given relationships and the related resources in the included member,
+// and writes it to w
+func SendCompoundEntity(w http.ResponseWriter, entity interface{}, status int, inclusions ...Inclusion) error {
+	document, err := documentWithMeta([]interface{}{entity}, func(w io.Writer) error {
+		return jsonapi.MarshalOnePayload(w, entity)
+	})
+	if err != nil {
+		return err
+	}
+	data, _ := document["data"].(map[string]interface{})
+	relationships, _ := data["relationships"].(map[string]interface{})
+	if relationships == nil {
+		relationships = make(map[string]interface{})
+		data["relationships"] = relationships
+	}
+	included, _ := document["included"].([]interface{})
+	for _, inclusion := range inclusions {
+		if inclusion.Entity == nil {
+			relationships[inclusion.Relationship] = linkageResponse{}
+			continue
+		}
+		related, err := documentWithMeta([]interface{}{inclusion.Entity}, func(w io.Writer) error {
+			return jsonapi.MarshalOnePayloadWithoutIncluded(w, inclusion.Entity)
+		})
+		if err != nil {
+			return err
+		}
+		object, _ := related["data"].(map[string]interface{})
+		relationships[inclusion.Relationship] = linkageResponse{
+			Data: map[string]interface{}{
+				"type": object["type"],
+				"id":   object["id"],
+			},
+		}
+		included = append(included, object)
+	}
+	if len(included) > 0 {
+		document["included"] = included
+	}
+	if etag := ETag(entity); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(document)
+}
@@ -0,0 +1,68 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Metable represent an entity with non-standard meta information,
+// sent in the meta member of its resource object
+type Metable interface {
+	Meta() map[string]interface{}
+}
+
+// hasMeta reports whether one of the given entities has meta information
+func hasMeta(entities []interface{}) bool {
+	for _, entity := range entities {
+		if m, ok := entity.(Metable); ok && m.Meta() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalWithMeta writes the document produced by marshal to w, after adding
+// the meta information of the given entities to their resource objects.
+// entities must be in the same order as the primary data of the document.
+func marshalWithMeta(w io.Writer, entities []interface{}, marshal func(w io.Writer) error) error {
+	document, err := documentWithMeta(entities, marshal)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(document)
+}
+
+// documentWithMeta returns the document produced by marshal, decoded, after
+// adding the meta information of the given entities to their resource objects
+func documentWithMeta(entities []interface{}, marshal func(w io.Writer) error) (map[string]interface{}, error) {
+	buf := new(bytes.Buffer)
+	if err := marshal(buf); err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	switch data := document["data"].(type) {
+	case map[string]interface{}:
+		setMeta(data, entities[0])
+	case []interface{}:
+		for i, node := range data {
+			if object, ok := node.(map[string]interface{}); ok && i < len(entities) {
+				setMeta(object, entities[i])
+			}
+		}
+	}
+	return document, nil
+}
+
+func setMeta(object map[string]interface{}, entity interface{}) {
+	if m, ok := entity.(Metable); ok {
+		if meta := m.Meta(); meta != nil {
+			object["meta"] = meta
+		}
+	}
+}
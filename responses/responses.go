@@ -2,7 +2,12 @@ package responses
 
 import (
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/shwoodard/jsonapi"
 	"github.com/torrent-viewer/backend/herr"
@@ -34,13 +39,26 @@ func SendErrors(w http.ResponseWriter, errcode int, e herr.Errors) error {
 
 // SendEntity marshalls the given entity and writes it to w
 func SendEntity(w http.ResponseWriter, entity interface{}, status int) error {
+	if etag := ETag(entity); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
 	w.WriteHeader(status)
+	if hasMeta([]interface{}{entity}) {
+		return marshalWithMeta(w, []interface{}{entity}, func(w io.Writer) error {
+			return jsonapi.MarshalOnePayload(w, entity)
+		})
+	}
 	return jsonapi.MarshalOnePayload(w, entity)
 }
 
 // SendEntities marshalls the given entities and writes them to w
 func SendEntities(w http.ResponseWriter, entities []interface{}) error {
 	w.WriteHeader(http.StatusOK)
+	if hasMeta(entities) {
+		return marshalWithMeta(w, entities, func(w io.Writer) error {
+			return jsonapi.MarshalManyPayload(w, entities)
+		})
+	}
 	return jsonapi.MarshalManyPayload(w, entities)
 }
 
@@ -49,3 +67,79 @@ func SendNoContent(w http.ResponseWriter) error {
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
+
+// SendNotModified sends a HTTP 304 with the ETag of the given entity to the client
+func SendNotModified(w http.ResponseWriter, entity interface{}) error {
+	if etag := ETag(entity); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return nil
+}
+
+type versioned interface {
+	GetID() int
+	GetVersion() int
+}
+
+// ETag returns the strong entity tag of the given entity,
+// or an empty string if the entity is not versioned.
+// The meta information of the entity, which changes without a new version,
+// is fingerprinted into the tag.
+func ETag(entity interface{}) string {
+	v, ok := entity.(versioned)
+	if !ok {
+		return ""
+	}
+	if m, ok := entity.(Metable); ok {
+		if meta := m.Meta(); meta != nil {
+			if encoded, err := json.Marshal(meta); err == nil {
+				hash := fnv.New32a()
+				hash.Write(encoded)
+				return fmt.Sprintf("\"%d-%d-%08x\"", v.GetID(), v.GetVersion(), hash.Sum32())
+			}
+		}
+	}
+	return fmt.Sprintf("\"%d-%d\"", v.GetID(), v.GetVersion())
+}
+
+// VersionTag returns the part of an entity tag given by ETag identifying
+// the version of the entity, without the fingerprint of its meta information
+func VersionTag(etag string) string {
+	parts := strings.SplitN(strings.Trim(etag, "\""), "-", 3)
+	if len(parts) < 2 {
+		return etag
+	}
+	return fmt.Sprintf("\"%s-%s\"", parts[0], parts[1])
+}
+
+// ResourceIdentifier identifies a single resource in the linkage of a relationship
+type ResourceIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type linkageResponse struct {
+	Data interface{} `json:"data"`
+}
+
+// SendLinkage writes the linkage of a relationship to the resources
+// of the given type and IDs to w.
+// The linkage of a to-one relationship is null if ids is empty.
+func SendLinkage(w http.ResponseWriter, resourceType string, ids []int, toMany bool) error {
+	identifiers := make([]ResourceIdentifier, len(ids), len(ids))
+	for i, id := range ids {
+		identifiers[i] = ResourceIdentifier{
+			Type: resourceType,
+			ID:   strconv.Itoa(id),
+		}
+	}
+	response := linkageResponse{}
+	if toMany {
+		response.Data = identifiers
+	} else if len(identifiers) > 0 {
+		response.Data = identifiers[0]
+	}
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(response)
+}
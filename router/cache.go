@@ -0,0 +1,82 @@
+package router
+
+import "net/http"
+
+// CacheConfig is used to configure the Cache-Control header of the
+// responses.
+// `Routes` maps the names of routes, such as "shows.list", to their policy,
+// such as "private, max-age=60".
+// `Default` is the policy of the GET and HEAD routes missing from `Routes`.
+// Policies only apply to successful responses, and never replace the
+// Cache-Control header set by a handler.
+type CacheConfig struct {
+	Default string
+	Routes  map[string]string
+}
+
+type cache struct {
+	config CacheConfig
+	h      http.Handler
+}
+
+func (c cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	policy, ok := c.config.Routes[RouteName(r)]
+	if !ok && (r.Method == "GET" || r.Method == "HEAD") {
+		policy = c.config.Default
+	}
+	// WebSocket upgrades need the original writer to hijack the connection
+	if policy == "" || r.Header.Get("Upgrade") != "" {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	c.h.ServeHTTP(&cacheWriter{ResponseWriter: w, policy: policy}, r)
+}
+
+// cacheWriter sets the Cache-Control header once the status of the response
+// is known
+type cacheWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (cw *cacheWriter) WriteHeader(status int) {
+	if !cw.wroteHeader && status >= 200 {
+		cw.wroteHeader = true
+		cacheable := status < 300 || status == http.StatusNotModified
+		if cacheable && cw.Header().Get("Cache-Control") == "" {
+			cw.Header().Set("Cache-Control", cw.policy)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *cacheWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// CacheMiddleware creates a middleware setting the Cache-Control header of
+// the responses according to the policies of their routes
+func CacheMiddleware(config CacheConfig) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return cache{
+			config: config,
+			h:      handler,
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	r := NewRouter()
+	r.Use(CacheMiddleware(CacheConfig{
+		Default: "private, no-cache",
+		Routes: map[string]string{
+			"calendar.export": "private, max-age=900",
+			"tokens.view":     "no-store",
+		},
+	}))
+	status := http.StatusOK
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+	r.AddRoutes(Routes{
+		Route{Path: "/shows", Method: "GET", Name: "shows.list", Handler: handler},
+		Route{Path: "/shows", Method: "POST", Name: "shows.store", Handler: handler},
+		Route{Path: "/calendar.ics", Method: "GET", Name: "calendar.export", Handler: handler},
+		Route{Path: "/token", Method: "GET", Name: "tokens.view", Handler: handler},
+		Route{Path: "/events", Method: "GET", Name: "events.stream", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Write([]byte(": heartbeat\n\n"))
+		}},
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		policy string
+	}{
+		{"GET", "/shows", http.StatusOK, "private, no-cache"},
+		{"GET", "/shows", http.StatusNotModified, "private, no-cache"},
+		{"GET", "/shows", http.StatusNotFound, ""},
+		{"POST", "/shows", http.StatusCreated, ""},
+		{"GET", "/calendar.ics", http.StatusOK, "private, max-age=900"},
+		{"GET", "/token", http.StatusOK, "no-store"},
+		{"GET", "/events", http.StatusOK, "no-cache"},
+	}
+	for _, test := range tests {
+		status = test.status
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if policy := w.Header().Get("Cache-Control"); policy != test.policy {
+			t.Errorf("Expected %s %s with HTTP %d to have policy %q, got %q", test.method, test.path, test.status, test.policy, policy)
+		}
+	}
+}
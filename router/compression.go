@@ -0,0 +1,318 @@
+package router
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressionConfig is used to configure the compression of the responses.
+// `MinSize` is the size in bytes from which a response is compressed,
+// 1024 if it is 0.
+// `Level` is the compression level, as defined by compress/flate, the default
+// one if it is 0.
+// `Except` are the names of the routes whose responses are never compressed,
+// such as streams whose events must reach the clients as soon as they are
+// written.
+type CompressionConfig struct {
+	MinSize int
+	Level   int
+	Except  []string
+}
+
+const defaultCompressionMinSize = 1024
+
+// compressionCodings are the content codings of the compressed responses
+var compressionCodings = []string{"gzip", "deflate"}
+
+// encoder is implemented by the gzip and zlib writers
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoding is a content coding, with a pool of its encoders
+type encoding struct {
+	name string
+	pool *sync.Pool
+}
+
+type compression struct {
+	config    CompressionConfig
+	except    map[string]bool
+	encodings []encoding
+	h         http.Handler
+}
+
+func (c compression) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// WebSocket upgrades hijack the connection, and HEAD requests have no
+	// body to compress
+	if c.except[RouteName(r)] || r.Method == "HEAD" || r.Header.Get("Upgrade") != "" {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	e := c.negotiate(r.Header.Get("Accept-Encoding"))
+	if e == nil {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	cw := &compressWriter{
+		ResponseWriter: w,
+		encoding:       e,
+		minSize:        c.config.MinSize,
+		ifNoneMatch:    r.Header.Get("If-None-Match"),
+	}
+	defer cw.close()
+	c.h.ServeHTTP(cw, r)
+}
+
+// negotiate returns the encoding preferred by the client according to the
+// given Accept-Encoding header, or nil if none is accepted.
+// Encodings with the same weight are picked in the order of the server.
+func (c compression) negotiate(header string) *encoding {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		weights[name] = weight
+	}
+	var best *encoding
+	bestWeight := 0.0
+	for i, e := range c.encodings {
+		weight, ok := weights[e.name]
+		if !ok {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = &c.encodings[i], weight
+		}
+	}
+	return best
+}
+
+// compressible reports whether responses of the given Content-Type are worth
+// compressing
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	// Events must reach the clients as soon as they are written, rather
+	// than wait in the encoder
+	if contentType == "text/event-stream" {
+		return false
+	}
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.HasSuffix(contentType, "/json") ||
+		strings.HasSuffix(contentType, "+json") ||
+		strings.HasSuffix(contentType, "/xml") ||
+		strings.HasSuffix(contentType, "+xml") ||
+		contentType == "application/javascript"
+}
+
+// compressWriter buffers the beginning of a response until it knows whether
+// it is worth compressing, which is decided once the response reaches the
+// minimum size, is flushed, or ends
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    *encoding
+	minSize     int
+	ifNoneMatch string
+
+	status  int
+	buffer  []byte
+	decided bool
+	encoder encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+	// Informational responses are followed by the actual one
+	if status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	h := cw.Header()
+	// A 304 carries the entity tag of the representation the client has,
+	// which is the compressed one if that is the tag it sent
+	if status == http.StatusNotModified {
+		if etag := EncodedETag(h.Get("ETag"), cw.encoding.name); etag != h.Get("ETag") && strings.Contains(cw.ifNoneMatch, etag) {
+			h.Set("ETag", etag)
+		}
+	}
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || (h.Get("Content-Type") != "" && !compressible(h.Get("Content-Type"))) {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.buffer = append(cw.buffer, p...)
+		if len(cw.buffer) >= cw.minSize {
+			if err := cw.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// start sends the headers, compressing the rest of the response if compress
+// is true and the buffered content is compressible
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	h := cw.Header()
+	// The type is detected before compression, as the server would detect
+	// the compressed data otherwise
+	if h.Get("Content-Type") == "" && len(cw.buffer) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buffer))
+	}
+	if compress && compressible(h.Get("Content-Type")) && h.Get("Content-Encoding") == "" {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding.name)
+		// The encoded bytes differ from the ones of the entity tag
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", EncodedETag(etag, cw.encoding.name))
+		}
+		cw.encoder = cw.encoding.pool.Get().(encoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buffer := cw.buffer
+	cw.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buffer)
+	} else {
+		_, err = cw.ResponseWriter.Write(buffer)
+	}
+	return err
+}
+
+// Flush sends what was written so far, uncompressed if the response is
+// still too small to be compressed
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.start(false)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close ends the response, sending it uncompressed if it never reached the
+// minimum size
+func (cw *compressWriter) close() {
+	if cw.status != 0 && !cw.decided {
+		cw.start(false)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+		cw.encoder.Reset(nil)
+		cw.encoding.pool.Put(cw.encoder)
+		cw.encoder = nil
+	}
+}
+
+// EncodedETag returns the strong entity tag of the representation identified
+// by etag once compressed with the given content coding, such as "1-2-gzip"
+// for "1-2". Weak entity tags are returned as they are.
+func EncodedETag(etag string, coding string) string {
+	if len(etag) < 2 || !strings.HasPrefix(etag, "\"") || !strings.HasSuffix(etag, "\"") {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + coding + "\""
+}
+
+// DecodedETag returns the entity tag of the uncompressed representation of
+// an entity tag given by EncodedETag, or etag itself if it is not one.
+func DecodedETag(etag string) string {
+	for _, name := range compressionCodings {
+		if suffix := "-" + name + "\""; strings.HasSuffix(etag, suffix) && strings.HasPrefix(etag, "\"") {
+			return etag[:len(etag)-len(suffix)] + "\""
+		}
+	}
+	return etag
+}
+
+// CompressionMiddleware creates a middleware compressing the responses with
+// gzip or deflate, as negotiated with the Accept-Encoding header.
+// Small responses, responses whose type is not compressible, and responses
+// already encoded by their handler are sent as they are.
+// Compressed responses have strong entity tags of their own, given by
+// EncodedETag, as they do not identify the bytes sent anymore.
+func CompressionMiddleware(config CompressionConfig) Middleware {
+	if config.MinSize <= 0 {
+		config.MinSize = defaultCompressionMinSize
+	}
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	}
+	level := config.Level
+	// Invalid levels are reported here rather than when responding
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		panic(err)
+	}
+	except := map[string]bool{}
+	for _, name := range config.Except {
+		except[name] = true
+	}
+	encodings := []encoding{
+		{name: "gzip", pool: &sync.Pool{New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(nil, level)
+			return w
+		}}},
+		// The deflate coding of HTTP is the zlib format
+		{name: "deflate", pool: &sync.Pool{New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(nil, level)
+			return w
+		}}},
+	}
+	return func(handler http.Handler) http.Handler {
+		return compression{
+			config:    config,
+			except:    except,
+			encodings: encodings,
+			h:         handler,
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat(`{"type":"shows","id":"1"}`, 100)
+	r := NewRouter()
+	r.Use(CompressionMiddleware(CompressionConfig{MinSize: 512, Except: []string{"events.stream"}}))
+	r.AddRoutes(Routes{
+		Route{Path: "/large", Method: "GET", Name: "large", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"1-2"`)
+			// Written in small parts, to be buffered until the minimum size
+			for i := 0; i < len(large); i += 100 {
+				w.Write([]byte(large[i : i+100]))
+			}
+		}},
+		Route{Path: "/unchanged", Method: "GET", Name: "unchanged", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"1-2"`)
+			w.WriteHeader(http.StatusNotModified)
+		}},
+		Route{Path: "/small", Method: "GET", Name: "small", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"data":null}`))
+		}},
+		Route{Path: "/image", Method: "GET", Name: "image", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(large))
+		}},
+		Route{Path: "/events", Method: "GET", Name: "events.stream", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(large))
+		}},
+		Route{Path: "/stream", Method: "GET", Name: "stream", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: 1\n\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(large))
+		}},
+	})
+
+	request := func(path string, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/large", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip response, got %v", w.Header())
+	}
+	if w.Header().Get("Content-Type") != "application/vnd.api+json; charset=UTF-8" {
+		t.Errorf("Expected the Content-Type of the router, got %s", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("ETag") != `"1-2-gzip"` || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Unexpected headers %v", w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(reader); string(body) != large {
+		t.Errorf("Unexpected decompressed body %q", body)
+	}
+
+	w = request("/large", "gzip;q=0.5, deflate")
+	if w.Header().Get("Content-Encoding") != "deflate" || w.Header().Get("ETag") != `"1-2-deflate"` {
+		t.Fatalf("Expected a deflate response, got %v", w.Header())
+	}
+	zreader, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zreader); string(body) != large {
+		t.Errorf("Unexpected decompressed body %q", body)
+	}
+
+	for _, acceptEncoding := range []string{"", "identity", "gzip;q=0, deflate;q=0", "br", "*;q=0"} {
+		if w := request("/large", acceptEncoding); w.Header().Get("Content-Encoding") != "" || w.Header().Get("ETag") != `"1-2"` || w.Body.String() != large {
+			t.Errorf("Expected no compression with Accept-Encoding %q, got %v", acceptEncoding, w.Header())
+		}
+	}
+	if w := request("/large", "*"); w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected a wildcard to accept gzip, got %v", w.Header())
+	}
+
+	// Not modified responses carry the tag the client has
+	for ifNoneMatch, expected := range map[string]string{`"1-2-gzip"`: `"1-2-gzip"`, `"1-2"`: `"1-2"`} {
+		req := httptest.NewRequest("GET", "/unchanged", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotModified || w.Header().Get("ETag") != expected {
+			t.Errorf("Expected HTTP %d with ETag %s for %s, got HTTP %d with %v", http.StatusNotModified, expected, ifNoneMatch, w.Code, w.Header())
+		}
+	}
+
+	w = request("/small", "gzip")
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"data":null}` {
+		t.Errorf("Expected a small response to be sent as is, got HTTP %d with %v", w.Code, w.Header())
+	}
+	for _, path := range []string{"/image", "/events"} {
+		if w := request(path, "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != large {
+			t.Errorf("Expected %s not to be compressed, got %v", path, w.Header())
+		}
+	}
+	w = request("/stream", "gzip")
+	if w.Header().Get("Content-Encoding") != "" || !w.Flushed || w.Body.String() != "data: 1\n\n"+large {
+		t.Errorf("Expected a flushed response to be sent as is, got %v", w.Header())
+	}
+}
+
+func TestEncodedETag(t *testing.T) {
+	for etag, expected := range map[string]string{
+		`"1-2"`:          `"1-2-gzip"`,
+		`"1-2-0a1b2c3d"`: `"1-2-0a1b2c3d-gzip"`,
+		`W/"1-2"`:        `W/"1-2"`,
+		"":               "",
+	} {
+		encoded := EncodedETag(etag, "gzip")
+		if encoded != expected {
+			t.Errorf("Expected %s to be encoded as %s, got %s", etag, expected, encoded)
+		}
+		if decoded := DecodedETag(encoded); decoded != etag {
+			t.Errorf("Expected %s to be decoded as %s, got %s", encoded, etag, decoded)
+		}
+	}
+	if decoded := DecodedETag(`"1-2-deflate"`); decoded != `"1-2"` {
+		t.Errorf("Expected the deflate coding to be removed, got %s", decoded)
+	}
+}
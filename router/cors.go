@@ -0,0 +1,138 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is used to configure the Cross-Origin Resource Sharing of the
+// Router.
+// `Origins` are the origins allowed to call the API, such as
+// "https://app.example.com". A "*" matches any part of an origin, as in
+// "https://*.example.com", and "*" alone matches every origin.
+// `Methods` and `Headers` are the methods and request headers allowed, the
+// ones used by the API if they are empty.
+// `ExposedHeaders` are the response headers readable by the origins, the
+// ones set by the API if it is empty.
+// `Credentials` allows requests with cookies or HTTP authentication.
+// `MaxAge` is the time browsers may cache the answers to preflight requests.
+type CORSConfig struct {
+	Origins        []string
+	Methods        []string
+	Headers        []string
+	ExposedHeaders []string
+	Credentials    bool
+	MaxAge         time.Duration
+}
+
+// The default headers include Username and Password, which carry the
+// credentials checked by auth.Users.
+var (
+	defaultCORSMethods        = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders        = []string{"Accept", "Content-Type", "If-Match", "If-None-Match", "Last-Event-ID", "Password", "Username", "X-Request-Id"}
+	defaultCORSExposedHeaders = []string{"ETag", "Location", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "X-Request-Id"}
+)
+
+type cors struct {
+	config CORSConfig
+	h      http.Handler
+}
+
+func (c cors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+	if !c.allowedOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	// The origin is sent back rather than "*", which is not allowed along
+	// with credentials
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.config.Credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.config.ExposedHeaders, ", "))
+		c.h.ServeHTTP(w, r)
+		return
+	}
+
+	// Preflight requests are answered here, as no route handles OPTIONS and
+	// browsers send them without credentials
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	if !containsFold(c.config.Methods, r.Header.Get("Access-Control-Request-Method")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" && !containsFold(c.config.Headers, header) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.config.Methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.config.Headers, ", "))
+	if c.config.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.config.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c cors) allowedOrigin(origin string) bool {
+	for _, pattern := range c.config.Origins {
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		star := strings.Index(pattern, "*")
+		if star < 0 {
+			continue
+		}
+		prefix, suffix := pattern[:star], pattern[star+1:]
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware creates a CORS middleware that can be used by the Router.
+// It must be the last middleware used, so that it runs first: preflight
+// requests are answered before reaching the other middlewares.
+func CORSMiddleware(config CORSConfig) Middleware {
+	if len(config.Methods) == 0 {
+		config.Methods = defaultCORSMethods
+	}
+	if len(config.Headers) == 0 {
+		config.Headers = defaultCORSHeaders
+	}
+	if len(config.ExposedHeaders) == 0 {
+		config.ExposedHeaders = defaultCORSExposedHeaders
+	}
+	return func(handler http.Handler) http.Handler {
+		return cors{
+			config: config,
+			h:      handler,
+		}
+	}
+}
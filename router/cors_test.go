@@ -0,0 +1,82 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	r := NewRouter()
+	r.Use(ContentTypeMiddleware([]string{"application/vnd.api+json"}))
+	r.Use(FirewallMiddleware(FirewallConfig{
+		Guard: func(r *http.Request) (string, bool) {
+			return r.Header.Get("Username"), r.Header.Get("Password") != ""
+		},
+		Only: []string{"^/shows"},
+	}))
+	r.Use(CORSMiddleware(CORSConfig{
+		Origins:     []string{"https://app.example.com", "https://*.example.org"},
+		Credentials: true,
+		MaxAge:      10 * time.Minute,
+	}))
+	r.AddRoute(Route{Path: "/shows", Handler: func(w http.ResponseWriter, r *http.Request) {}, Method: "GET", Name: "shows.list"})
+
+	request := func(method string, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/shows", nil)
+		req.Header.Set("Origin", origin)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	preflight := map[string]string{
+		"Access-Control-Request-Method":  "PATCH",
+		"Access-Control-Request-Headers": "content-type, if-match",
+	}
+	w := request("OPTIONS", "https://app.example.com", preflight)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected HTTP %d, got HTTP %d", http.StatusNoContent, w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Unexpected preflight headers %v", w.Header())
+	}
+	if w := request("OPTIONS", "https://beta.example.org", preflight); w.Code != http.StatusNoContent {
+		t.Errorf("Expected a wildcard origin to be allowed, got HTTP %d", w.Code)
+	}
+	for _, origin := range []string{"https://evil.example.com", "https://.example.org", "https://example.org"} {
+		if w := request("OPTIONS", origin, preflight); w.Code != http.StatusForbidden {
+			t.Errorf("Expected origin %s to be rejected, got HTTP %d", origin, w.Code)
+		}
+	}
+	// The credentials of auth.Users are sent in their own headers
+	credentials := map[string]string{
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "Username, Password",
+	}
+	if w := request("OPTIONS", "https://app.example.com", credentials); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the credential headers to be allowed, got HTTP %d", w.Code)
+	}
+	if w := request("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "Authorization"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an unknown header to be rejected, got HTTP %d", w.Code)
+	}
+	if w := request("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "TRACE"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an unknown method to be rejected, got HTTP %d", w.Code)
+	}
+
+	w = request("GET", "https://app.example.com", map[string]string{"Username": "alice", "Password": "secret"})
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected an allowed request, got HTTP %d with %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Error("Expected the response headers to be exposed")
+	}
+	w = request("GET", "https://evil.example.com", map[string]string{"Username": "alice", "Password": "secret"})
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for another origin, got %v", w.Header())
+	}
+}
@@ -1,10 +1,24 @@
 package router
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net/http"
 	"time"
 	"regexp"
+
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/responses"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	requestIDKey
+	routeNameKey
 )
 
 type logger struct {
@@ -30,6 +44,11 @@ type contentType struct {
 }
 
 func (c contentType) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Requests without a body have no content to check
+	if r.ContentLength == 0 {
+		c.h.ServeHTTP(w, r)
+		return
+	}
 	for _, t := range c.accepted {
 		if t == r.Header.Get("Content-Type") {
 			c.h.ServeHTTP(w, r)
@@ -39,7 +58,7 @@ func (c contentType) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusUnsupportedMediaType)
 }
 
-// ContentTypeMiddleware restricts the Content-Type that can be requested
+// ContentTypeMiddleware restricts the Content-Type of the requests bodies
 func ContentTypeMiddleware(accepted []string) Middleware {
 	return func(handler http.Handler) http.Handler {
 		return contentType{
@@ -50,14 +69,16 @@ func ContentTypeMiddleware(accepted []string) Middleware {
 }
 
 // Guard is a function used to authenticate user based on the current Request.
-// It returns bool if the user could be authenticated, false otherwise.
-type Guard func(r *http.Request) bool
+// It returns the authenticated principal and true if the user could be
+// authenticated, false otherwise.
+type Guard func(r *http.Request) (string, bool)
 
 type firewall struct {
-	only   []*regexp.Regexp
-	except []*regexp.Regexp
-	guard  Guard
-	h      http.Handler
+	only     []*regexp.Regexp
+	except   []*regexp.Regexp
+	guard    Guard
+	failures *failureLimiter
+	h        http.Handler
 }
 
 // FirewallConfig is used to configure a Firewall.
@@ -67,40 +88,48 @@ type firewall struct {
 // not protected by the firewall.
 // If both `Only` and `Except` are given, only `Only` is used.
 // `Guard` is the function used to authenticate the user
+// `Failures` limits the failed authentications of each IP address, unless
+// its Rate is 0. Once exceeded, the guard is not called anymore until the
+// bucket is refilled, so that credentials cannot be guessed.
+// `FailureStore` keeps the buckets of the failures, in memory if it is nil.
 type FirewallConfig struct {
-	Only   []string
-	Except []string
-	Guard  Guard
+	Only         []string
+	Except       []string
+	Guard        Guard
+	Failures     Limit
+	FailureStore RateLimitStore
 }
 
 func (fw firewall) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	authenticated := false
+	protected := false
 	if len(fw.only) > 0 {
 		for _, pattern := range fw.only {
 			if pattern.MatchString(r.URL.Path) == true {
-				authenticated = fw.guard(r)
-				if authenticated {
-					break;
-				}
+				protected = true
+				break;
 			}
 		}
-		if authenticated == false {
-			w.WriteHeader(401)
-			return
-		}
 	} else if len(fw.except) > 0 {
+		protected = true
 		for _, pattern := range fw.except {
-			if pattern.MatchString(r.URL.Path) == false {
-				authenticated = fw.guard(r)
-				if authenticated {
-					break;
-				}
+			if pattern.MatchString(r.URL.Path) == true {
+				protected = false
+				break;
 			}
 		}
+	}
+	if protected {
+		if fw.failures.exhausted(w, r) {
+			responses.SendError(w, herr.TooManyRequestsError)
+			return
+		}
+		principal, authenticated := fw.guard(r)
 		if authenticated == false {
+			fw.failures.record(r)
 			w.WriteHeader(401)
 			return
 		}
+		r = r.WithContext(context.WithValue(r.Context(), principalKey, principal))
 	}
 	fw.h.ServeHTTP(w, r)
 }
@@ -117,12 +146,60 @@ func firewallCompileSlice(patterns []string) []*regexp.Regexp {
 func FirewallMiddleware(config FirewallConfig) Middleware {
 	onlyCompiled := firewallCompileSlice(config.Only)
 	exceptCompiled := firewallCompileSlice(config.Except)
+	var failures *failureLimiter
+	if config.Failures.Rate != 0 {
+		checkLimit(config.Failures)
+		if config.FailureStore == nil {
+			config.FailureStore = NewMemoryRateLimitStore()
+		}
+		failures = &failureLimiter{limit: config.Failures, store: config.FailureStore}
+	}
 	return func(handler http.Handler) http.Handler {
 		return firewall{
-			only:   onlyCompiled,
-			except: exceptCompiled,
-			h:      handler,
-			guard:  config.Guard,
+			only:     onlyCompiled,
+			except:   exceptCompiled,
+			h:        handler,
+			guard:    config.Guard,
+			failures: failures,
+		}
+	}
+}
+
+// Principal returns the principal authenticated by a Firewall for the current
+// Request, or an empty string if the route is not protected.
+func Principal(r *http.Request) string {
+	principal, _ := r.Context().Value(principalKey).(string)
+	return principal
+}
+
+type requestID struct {
+	h http.Handler
+}
+
+func (rid requestID) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get("X-Request-Id")
+	if id == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			log.Println("Could not generate request ID:", err)
 		}
+		id = hex.EncodeToString(buf)
+	}
+	w.Header().Set("X-Request-Id", id)
+	rid.h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+}
+
+// RequestIDMiddleware identifies each request by the X-Request-Id header
+// given by the client, or a random one, and sends it back in the response
+func RequestIDMiddleware(handler http.Handler) http.Handler {
+	return requestID{
+		h: handler,
 	}
 }
+
+// RequestID returns the identifier given to the current Request
+// by the RequestIDMiddleware
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
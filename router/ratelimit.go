@@ -0,0 +1,242 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/torrent-viewer/backend/herr"
+	"github.com/torrent-viewer/backend/responses"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second, holding at
+// most Burst tokens. Each request takes a token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitStore keeps the token buckets of a rate limiter
+type RateLimitStore interface {
+	// Take refills the bucket of the given key according to the limit, then
+	// takes a token from it if there is one.
+	// It returns whether a token was taken, and the tokens left.
+	Take(key string, limit Limit, now time.Time) (bool, float64, error)
+	// Tokens returns the tokens of the bucket of the given key, refilled
+	// according to the limit, without taking any.
+	Tokens(key string, limit Limit, now time.Time) (float64, error)
+}
+
+// RateLimitConfig is used to configure a rate limiter.
+// `Default` is the limit of every route, unless the name of the route has
+// its own limit in `Routes`.
+// `Store` keeps the buckets, in memory if it is nil.
+// Clients are identified by their principal when a Firewall authenticated
+// them, by their IP address otherwise.
+type RateLimitConfig struct {
+	Default Limit
+	Routes  map[string]Limit
+	Store   RateLimitStore
+}
+
+type rateLimiter struct {
+	config RateLimitConfig
+	h      http.Handler
+}
+
+func (rl rateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limit := rl.config.Default
+	bucket := "*"
+	if override, ok := rl.config.Routes[RouteName(r)]; ok {
+		limit = override
+		bucket = RouteName(r)
+	}
+	key := clientKey(r) + "|" + bucket
+	allowed, remaining, err := rl.config.Store.Take(key, limit, time.Now())
+	if err != nil {
+		// Requests are denied when the store is not available, so that
+		// clients cannot go over their limit by overloading it
+		log.Println("Could not take a rate limit token:", err)
+		responses.SendError(w, herr.TooManyRequestsError)
+		return
+	}
+	reset := math.Ceil((float64(limit.Burst) - remaining) / limit.Rate)
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(remaining))))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(reset)))
+	if !allowed {
+		retryAfter := math.Ceil((1 - remaining) / limit.Rate)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		responses.SendError(w, herr.TooManyRequestsError)
+		return
+	}
+	rl.h.ServeHTTP(w, r)
+}
+
+// clientKey identifies the client of the request
+func clientKey(r *http.Request) string {
+	if principal := Principal(r); principal != "" {
+		return "user:" + principal
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// remoteIP returns the IP address of the client of the request
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// checkLimit panics if the limit would never refill its bucket
+func checkLimit(limit Limit) {
+	if limit.Rate <= 0 || math.IsInf(limit.Rate, 0) || math.IsNaN(limit.Rate) {
+		panic(fmt.Sprintf("router: invalid rate limit %v, its rate must be positive", limit))
+	}
+}
+
+// RateLimitMiddleware creates a rate limiter that can be used by the Router.
+// It must be used before the Firewalls, so that their principal is known.
+// The failed authentications are limited by the Firewalls themselves.
+// It panics if a limit has no positive rate.
+func RateLimitMiddleware(config RateLimitConfig) Middleware {
+	checkLimit(config.Default)
+	for _, limit := range config.Routes {
+		checkLimit(limit)
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	return func(handler http.Handler) http.Handler {
+		return rateLimiter{
+			config: config,
+			h:      handler,
+		}
+	}
+}
+
+// failureLimiter limits the failed authentications of each IP address
+type failureLimiter struct {
+	limit Limit
+	store RateLimitStore
+}
+
+func (fl *failureLimiter) key(r *http.Request) string {
+	return "auth:" + remoteIP(r)
+}
+
+// exhausted reports whether the client of the request failed to
+// authenticate too many times, and tells it when to try again.
+// Clients are blocked when the store is not available.
+func (fl *failureLimiter) exhausted(w http.ResponseWriter, r *http.Request) bool {
+	if fl == nil {
+		return false
+	}
+	tokens, err := fl.store.Tokens(fl.key(r), fl.limit, time.Now())
+	if err != nil {
+		log.Println("Could not load the failed authentications:", err)
+		return true
+	}
+	if tokens >= 1 {
+		return false
+	}
+	retryAfter := math.Ceil((1 - tokens) / fl.limit.Rate)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+	return true
+}
+
+// record takes a token from the bucket of the client of the request, which
+// failed to authenticate
+func (fl *failureLimiter) record(r *http.Request) {
+	if fl == nil {
+		return
+	}
+	if _, _, err := fl.store.Take(fl.key(r), fl.limit, time.Now()); err != nil {
+		log.Println("Could not record a failed authentication:", err)
+	}
+}
+
+// MemoryRateLimitStore is a RateLimitStore keeping the buckets in memory,
+// which are not shared between instances
+type MemoryRateLimitStore struct {
+	mutex   sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is the time when the bucket is full again
+	full time.Time
+}
+
+// maxIdleBuckets is the number of buckets over which the buckets that are
+// full again are forgotten
+const maxIdleBuckets = 10000
+
+// NewMemoryRateLimitStore creates an empty MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+// Take takes a token from the bucket of the given key
+func (s *MemoryRateLimitStore) Take(key string, limit Limit, now time.Time) (bool, float64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxIdleBuckets {
+			s.forgetFull(now)
+		}
+		b = &memoryBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = Refill(b.tokens, limit, now.Sub(b.updated))
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	missing := float64(limit.Burst) - b.tokens
+	b.full = now.Add(time.Duration(missing / limit.Rate * float64(time.Second)))
+	return allowed, b.tokens, nil
+}
+
+// Tokens returns the tokens of the bucket of the given key
+func (s *MemoryRateLimitStore) Tokens(key string, limit Limit, now time.Time) (float64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		return float64(limit.Burst), nil
+	}
+	return Refill(b.tokens, limit, now.Sub(b.updated)), nil
+}
+
+// forgetFull forgets the buckets that are full again, which are the same as
+// new buckets
+func (s *MemoryRateLimitStore) forgetFull(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Refill returns the tokens of a bucket holding the given tokens once
+// elapsed has passed
+func Refill(tokens float64, limit Limit, elapsed time.Duration) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * limit.Rate
+	}
+	return math.Min(tokens, float64(limit.Burst))
+}
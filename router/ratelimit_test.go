@@ -0,0 +1,162 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	r := NewRouter()
+	r.Use(RateLimitMiddleware(RateLimitConfig{
+		Default: Limit{Rate: 1, Burst: 2},
+		Routes: map[string]Limit{
+			"shows.store": {Rate: 0.1, Burst: 1},
+		},
+	}))
+	r.Use(FirewallMiddleware(FirewallConfig{
+		Guard: func(r *http.Request) (string, bool) {
+			username := r.Header.Get("Username")
+			return username, username != ""
+		},
+		Only: []string{"^/me$"},
+	}))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.AddRoute(Route{Path: "/shows", Handler: ok, Method: "GET", Name: "shows.list"})
+	r.AddRoute(Route{Path: "/shows", Handler: ok, Method: "POST", Name: "shows.store"})
+	r.AddRoute(Route{Path: "/me", Handler: ok, Method: "GET", Name: "me"})
+
+	request := func(method string, path string, username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if username != "" {
+			req.Header.Set("Username", username)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := request("GET", "/shows", "")
+		if w.Code != expected {
+			t.Errorf("Expected HTTP %d for request %d, got HTTP %d", expected, i, w.Code)
+		}
+	}
+	w := request("GET", "/shows", "")
+	if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Unexpected rate limit headers %v", w.Header())
+	}
+	// Routes with their own limit have their own bucket
+	if w := request("POST", "/shows", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, w.Code)
+	}
+	if w := request("POST", "/shows", ""); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "10" {
+		t.Errorf("Expected HTTP %d after 10 seconds, got HTTP %d after %s", http.StatusTooManyRequests, w.Code, w.Header().Get("Retry-After"))
+	}
+	// Authenticated users have their own bucket
+	if w := request("GET", "/me", "alice"); w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := Limit{Rate: 2, Burst: 1}
+	now := time.Now()
+	if allowed, _, _ := store.Take("ip:192.0.2.1", limit, now); !allowed {
+		t.Error("Expected the first request to be allowed")
+	}
+	if allowed, _, _ := store.Take("ip:192.0.2.1", limit, now.Add(100*time.Millisecond)); allowed {
+		t.Error("Expected the bucket to be empty")
+	}
+	if allowed, _, _ := store.Take("ip:192.0.2.1", limit, now.Add(600*time.Millisecond)); !allowed {
+		t.Error("Expected the bucket to be refilled")
+	}
+	store.forgetFull(now.Add(2 * time.Second))
+	if len(store.buckets) != 0 {
+		t.Errorf("Expected the full buckets to be forgotten, got %d", len(store.buckets))
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, config := range []RateLimitConfig{
+		{Default: Limit{Rate: 0, Burst: 10}},
+		{Default: Limit{Rate: 1, Burst: 10}, Routes: map[string]Limit{"shows.store": {Burst: 1}}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %v to be rejected", config)
+				}
+			}()
+			RateLimitMiddleware(config)
+		}()
+	}
+}
+
+func TestFirewallFailures(t *testing.T) {
+	r := NewRouter()
+	r.Use(FirewallMiddleware(FirewallConfig{
+		Guard: func(r *http.Request) (string, bool) {
+			return "alice", r.Header.Get("Password") == "secret"
+		},
+		Failures: Limit{Rate: 0.1, Burst: 2},
+		Only:     []string{"^/me$"},
+	}))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.AddRoute(Route{Path: "/me", Handler: ok, Method: "GET", Name: "me"})
+
+	request := func(password string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Password", password)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("secret", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, w.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if w := request("guess", "192.0.2.1:1234"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected HTTP %d for failure %d, got HTTP %d", http.StatusUnauthorized, i, w.Code)
+		}
+	}
+	// Once the failures are exhausted, even the right credentials are refused
+	if w := request("secret", "192.0.2.1:5678"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "10" {
+		t.Errorf("Expected HTTP %d after 10 seconds, got HTTP %d after %s", http.StatusTooManyRequests, w.Code, w.Header().Get("Retry-After"))
+	}
+	// Other addresses have their own bucket
+	if w := request("secret", "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusOK, w.Code)
+	}
+}
+
+// failingStore is a RateLimitStore that is never available
+type failingStore struct{}
+
+func (failingStore) Take(key string, limit Limit, now time.Time) (bool, float64, error) {
+	return false, 0, errors.New("store is down")
+}
+
+func (failingStore) Tokens(key string, limit Limit, now time.Time) (float64, error) {
+	return 0, errors.New("store is down")
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	r := NewRouter()
+	r.Use(RateLimitMiddleware(RateLimitConfig{
+		Default: Limit{Rate: 1, Burst: 2},
+		Store:   failingStore{},
+	}))
+	r.AddRoute(Route{Path: "/shows", Handler: func(w http.ResponseWriter, r *http.Request) {}, Method: "GET", Name: "shows.list"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/shows", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusTooManyRequests, w.Code)
+	}
+}
@@ -22,4 +22,37 @@ type Updatable interface {
 
 type Destroyable interface {
 	RouteDestroy(w http.ResponseWriter, r *http.Request)
+}
+
+type Restorable interface {
+	RouteRestore(w http.ResponseWriter, r *http.Request)
+}
+
+type Purgeable interface {
+	RoutePurge(w http.ResponseWriter, r *http.Request)
+}
+
+type RelationshipViewable interface {
+	Relationships() []string
+	RouteViewRelationship(w http.ResponseWriter, r *http.Request)
+}
+
+type RelationshipUpdatable interface {
+	Relationships() []string
+	RouteUpdateRelationship(w http.ResponseWriter, r *http.Request)
+}
+
+type RelationshipAddable interface {
+	Relationships() []string
+	RouteAddRelationship(w http.ResponseWriter, r *http.Request)
+}
+
+type RelationshipRemovable interface {
+	Relationships() []string
+	RouteRemoveRelationship(w http.ResponseWriter, r *http.Request)
+}
+
+type RelatedViewable interface {
+	Relationships() []string
+	RouteViewRelated(w http.ResponseWriter, r *http.Request)
 }
\ No newline at end of file
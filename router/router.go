@@ -1,9 +1,11 @@
 package router
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -35,6 +37,10 @@ func NewRouter() *Router {
 }
 
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var match mux.RouteMatch
+	if router.mux.Match(r, &match) && match.Route != nil {
+		r = r.WithContext(context.WithValue(r.Context(), routeNameKey, match.Route.GetName()))
+	}
 	var handler http.Handler = router.mux
 	for _, mw := range router.middlewares {
 		handler = mw(handler)
@@ -43,6 +49,13 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
+// RouteName returns the name of the route matching the current Request,
+// or an empty string if no route matches
+func RouteName(r *http.Request) string {
+	name, _ := r.Context().Value(routeNameKey).(string)
+	return name
+}
+
 // AddRoute adds a route to the router
 func (router *Router) AddRoute(route Route) *Router {
 	log.Printf("Registering route %-25s: %10s %s\n", route.Name, route.Method, route.Path)
@@ -104,9 +117,74 @@ func (router *Router) AddResource(prefix string, resource interface{}) *Router {
 			Name:    fmt.Sprintf("%s.delete", prefix),
 		})
 	}
+	if t, ok := resource.(Restorable); ok {
+		router.AddRoute(Route{
+			Path:    fmt.Sprintf("/%s/{id:[0-9]+}/restore", prefix),
+			Handler: t.RouteRestore,
+			Method:  "POST",
+			Name:    fmt.Sprintf("%s.restore", prefix),
+		})
+	}
+	if t, ok := resource.(Purgeable); ok {
+		router.AddRoute(Route{
+			Path:    fmt.Sprintf("/%s/{id:[0-9]+}/purge", prefix),
+			Handler: t.RoutePurge,
+			Method:  "DELETE",
+			Name:    fmt.Sprintf("%s.purge", prefix),
+		})
+	}
+	router.addRelationships(prefix, resource)
 	return router
 }
 
+// addRelationships adds the related resource and relationship routes
+// of a resource to the router
+func (router *Router) addRelationships(prefix string, resource interface{}) {
+	path := func(relationships []string) string {
+		return fmt.Sprintf("/%s/{id:[0-9]+}/relationships/{relationship:%s}", prefix, strings.Join(relationships, "|"))
+	}
+	if t, ok := resource.(RelatedViewable); ok && len(t.Relationships()) > 0 {
+		router.AddRoute(Route{
+			Path:    fmt.Sprintf("/%s/{id:[0-9]+}/{relationship:%s}", prefix, strings.Join(t.Relationships(), "|")),
+			Handler: t.RouteViewRelated,
+			Method:  "GET",
+			Name:    fmt.Sprintf("%s.related", prefix),
+		})
+	}
+	if t, ok := resource.(RelationshipViewable); ok && len(t.Relationships()) > 0 {
+		router.AddRoute(Route{
+			Path:    path(t.Relationships()),
+			Handler: t.RouteViewRelationship,
+			Method:  "GET",
+			Name:    fmt.Sprintf("%s.relationships.view", prefix),
+		})
+	}
+	if t, ok := resource.(RelationshipUpdatable); ok && len(t.Relationships()) > 0 {
+		router.AddRoute(Route{
+			Path:    path(t.Relationships()),
+			Handler: t.RouteUpdateRelationship,
+			Method:  "PATCH",
+			Name:    fmt.Sprintf("%s.relationships.update", prefix),
+		})
+	}
+	if t, ok := resource.(RelationshipAddable); ok && len(t.Relationships()) > 0 {
+		router.AddRoute(Route{
+			Path:    path(t.Relationships()),
+			Handler: t.RouteAddRelationship,
+			Method:  "POST",
+			Name:    fmt.Sprintf("%s.relationships.add", prefix),
+		})
+	}
+	if t, ok := resource.(RelationshipRemovable); ok && len(t.Relationships()) > 0 {
+		router.AddRoute(Route{
+			Path:    path(t.Relationships()),
+			Handler: t.RouteRemoveRelationship,
+			Method:  "DELETE",
+			Name:    fmt.Sprintf("%s.relationships.remove", prefix),
+		})
+	}
+}
+
 // Use adds a middleware to the router
 func (router *Router) Use(mw Middleware) *Router {
 	router.middlewares = append(router.middlewares, mw)
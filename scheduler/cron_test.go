@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	expressions := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+	for _, expression := range expressions {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Expected %q to be invalid", expression)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2017, time.March, 15, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2017, time.March, 15, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2017, time.March, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2017, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2017, time.March, 16, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2017, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2017, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2017, time.March, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 8-10,14 * * 1-5", time.Date(2017, time.March, 15, 14, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2017, time.March, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.expression)
+		if err != nil {
+			t.Errorf("Could not parse %q: %s", test.expression, err)
+			continue
+		}
+		if next := schedule.Next(from); !next.Equal(test.expected) {
+			t.Errorf("Expected %q to run at %s, got %s", test.expression, test.expected, next)
+		}
+	}
+}
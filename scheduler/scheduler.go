@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+// Job is a task run at each time matching its Schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func()
+}
+
+// owner identifies this instance when taking job locks
+var owner = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}()
+
+// Start runs job at each time matching its schedule. It never returns.
+// When several instances share the datastore, each occurrence of the job is
+// only run by the first instance taking its lock.
+func Start(job Job) {
+	next := job.Schedule.Next(time.Now())
+	for !next.IsZero() {
+		time.Sleep(time.Until(next))
+		following := job.Schedule.Next(next)
+		// The lock expires just before the following occurrence,
+		// so that any instance can take it then.
+		until := following.Add(-time.Second)
+		if following.IsZero() {
+			until = next.Add(time.Hour)
+		}
+		acquired, err := datastore.AcquireLock(job.Name, owner, until)
+		if err != nil {
+			log.Printf("Could not lock job %s: %s\n", job.Name, err.Detail)
+		} else if acquired {
+			job.Run()
+		}
+		// Occurrences missed while the job was running are skipped
+		next = job.Schedule.Next(time.Now())
+	}
+	log.Printf("Job %s will never run again\n", job.Name)
+}
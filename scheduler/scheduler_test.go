@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-scheduler-test.db")
+	datastore.Conn.AutoMigrate(&datastore.Lock{})
+	ret := m.Run()
+	datastore.Conn.DropTable(&datastore.Lock{})
+	os.Exit(ret)
+}
+
+func acquire(t *testing.T, owner string, until time.Time) bool {
+	acquired, err := datastore.AcquireLock("job", owner, until)
+	if err != nil {
+		t.Fatal(err.Detail)
+	}
+	return acquired
+}
+
+func TestJobLock(t *testing.T) {
+	if !acquire(t, "first", time.Now().Add(time.Hour)) {
+		t.Fatal("Expected the first instance to take the lock")
+	}
+	if acquire(t, "second", time.Now().Add(time.Hour)) {
+		t.Fatal("Expected the second instance not to take a held lock")
+	}
+	if !acquire(t, "first", time.Now().Add(-time.Minute)) {
+		t.Fatal("Expected the first instance to extend its lock")
+	}
+	if !acquire(t, "second", time.Now().Add(time.Hour)) {
+		t.Fatal("Expected the second instance to take an expired lock")
+	}
+	if err := datastore.ReleaseLock("job", "second"); err != nil {
+		t.Fatal(err.Detail)
+	}
+	if !acquire(t, "first", time.Now().Add(time.Hour)) {
+		t.Fatal("Expected the first instance to take a released lock")
+	}
+}
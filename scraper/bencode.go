@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"errors"
+	"strconv"
+)
+
+var errInvalidBencode = errors.New("invalid bencoded data")
+
+// decodeBencode decodes a bencoded value, returning it as a string, an int64,
+// a []interface{} or a map[string]interface{}
+func decodeBencode(data []byte) (interface{}, error) {
+	value, rest, err := decodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errInvalidBencode
+	}
+	return value, nil
+}
+
+func decodeValue(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errInvalidBencode
+	}
+	switch c := data[0]; {
+	case c == 'i':
+		end := indexByte(data, 'e')
+		if end < 0 {
+			return nil, nil, errInvalidBencode
+		}
+		n, err := strconv.ParseInt(string(data[1:end]), 10, 64)
+		if err != nil {
+			return nil, nil, errInvalidBencode
+		}
+		return n, data[end+1:], nil
+	case c == 'l':
+		list := []interface{}{}
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			value, rest, err := decodeValue(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, value)
+			data = rest
+		}
+		if len(data) == 0 {
+			return nil, nil, errInvalidBencode
+		}
+		return list, data[1:], nil
+	case c == 'd':
+		dict := make(map[string]interface{})
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			key, rest, err := decodeString(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			value, rest, err := decodeValue(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			dict[key] = value
+			data = rest
+		}
+		if len(data) == 0 {
+			return nil, nil, errInvalidBencode
+		}
+		return dict, data[1:], nil
+	case c >= '0' && c <= '9':
+		return decodeString(data)
+	}
+	return nil, nil, errInvalidBencode
+}
+
+func decodeString(data []byte) (string, []byte, error) {
+	colon := indexByte(data, ':')
+	if colon < 0 {
+		return "", nil, errInvalidBencode
+	}
+	length, err := strconv.Atoi(string(data[:colon]))
+	if err != nil || length < 0 || colon+1+length > len(data) {
+		return "", nil, errInvalidBencode
+	}
+	return string(data[colon+1 : colon+1+length]), data[colon+1+length:], nil
+}
+
+func indexByte(data []byte, c byte) int {
+	for i, b := range data {
+		if b == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// scrapeURL returns the scrape URL of an HTTP tracker from its announce URL,
+// following the convention of replacing the last "announce" path segment
+func scrapeURL(announce string) (*url.URL, error) {
+	u, err := url.Parse(announce)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.LastIndex(u.Path, "/")
+	if i < 0 || !strings.HasPrefix(u.Path[i+1:], "announce") {
+		return nil, fmt.Errorf("tracker %s does not support scraping", announce)
+	}
+	u.Path = u.Path[:i+1] + "scrape" + strings.TrimPrefix(u.Path[i+1:], "announce")
+	return u, nil
+}
+
+// scrapeHTTP scrapes the given info hashes from an HTTP tracker
+func scrapeHTTP(client *http.Client, tracker string, hashes []string) (map[string]Stats, error) {
+	u, err := scrapeURL(tracker)
+	if err != nil {
+		return nil, err
+	}
+	query := u.RawQuery
+	for _, hash := range hashes {
+		raw, err := hex.DecodeString(hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid info hash %q", hash)
+		}
+		if query != "" {
+			query += "&"
+		}
+		query += "info_hash=" + url.QueryEscape(string(raw))
+	}
+	u.RawQuery = query
+	response, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tracker %s answered HTTP %d", tracker, response.StatusCode)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := decodeBencode(body)
+	if err != nil {
+		return nil, fmt.Errorf("tracker %s: %s", tracker, err)
+	}
+	document, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tracker %s: unexpected scrape response", tracker)
+	}
+	if reason, ok := document["failure reason"].(string); ok {
+		return nil, fmt.Errorf("tracker %s: %s", tracker, reason)
+	}
+	files, ok := document["files"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tracker %s: unexpected scrape response", tracker)
+	}
+	stats := make(map[string]Stats)
+	for raw, file := range files {
+		fields, ok := file.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		complete, _ := fields["complete"].(int64)
+		incomplete, _ := fields["incomplete"].(int64)
+		downloaded, _ := fields["downloaded"].(int64)
+		stats[hex.EncodeToString([]byte(raw))] = Stats{
+			Seeders:   int(complete),
+			Leechers:  int(incomplete),
+			Completed: int(downloaded),
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,191 @@
+package scraper
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/torrent"
+)
+
+// ErrBackedOff is returned when scraping a tracker which failed recently
+var ErrBackedOff = errors.New("the tracker is backed off after failing")
+
+// Stats is the health of a torrent according to a tracker
+type Stats struct {
+	Seeders   int
+	Leechers  int
+	Completed int
+}
+
+// Config describes how trackers are scraped.
+// Trackers failing repeatedly are backed off exponentially, from Backoff up
+// to MaxBackoff. Zero values are replaced by defaults.
+type Config struct {
+	BatchSize  int
+	Timeout    time.Duration
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Scraper scrapes the HTTP and UDP trackers of torrents
+type Scraper struct {
+	config   Config
+	client   *http.Client
+	now      func() time.Time
+	mutex    sync.Mutex
+	failures map[string]failure
+}
+
+// failure is the backoff state of a tracker
+type failure struct {
+	count   int
+	retryAt time.Time
+}
+
+// New creates a Scraper
+func New(config Config) *Scraper {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 15 * time.Second
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = 30 * time.Minute
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 24 * time.Hour
+	}
+	return &Scraper{
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		now:      time.Now,
+		failures: make(map[string]failure),
+	}
+}
+
+// Scrape returns the Stats of the given info hashes reported by a tracker,
+// which are scraped in batches.
+// Info hashes unknown to the tracker are missing from the result.
+func (s *Scraper) Scrape(tracker string, hashes []string) (map[string]Stats, error) {
+	if !s.available(tracker) {
+		return nil, ErrBackedOff
+	}
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	size := s.config.BatchSize
+	if u.Scheme == "udp" && size > udpMaxHashes {
+		size = udpMaxHashes
+	}
+	stats := make(map[string]Stats)
+	for start := 0; start < len(hashes); start += size {
+		end := start + size
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		var batch map[string]Stats
+		switch u.Scheme {
+		case "http", "https":
+			batch, err = scrapeHTTP(s.client, tracker, hashes[start:end])
+		case "udp":
+			batch, err = scrapeUDP(tracker, hashes[start:end], s.config.Timeout)
+		default:
+			err = errors.New("unsupported tracker protocol " + u.Scheme)
+		}
+		if err != nil {
+			s.fail(tracker)
+			return nil, err
+		}
+		for hash, st := range batch {
+			stats[hash] = st
+		}
+	}
+	s.succeed(tracker)
+	return stats, nil
+}
+
+// available reports whether a tracker can be scraped, as it is not backed off
+func (s *Scraper) available(tracker string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, ok := s.failures[tracker]
+	return !ok || !s.now().Before(f.retryAt)
+}
+
+// fail backs off a tracker, twice as long as the previous time
+func (s *Scraper) fail(tracker string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f := s.failures[tracker]
+	delay := s.config.Backoff << uint(f.count)
+	if delay > s.config.MaxBackoff || delay <= 0 {
+		delay = s.config.MaxBackoff
+	}
+	f.count++
+	f.retryAt = s.now().Add(delay)
+	s.failures[tracker] = f
+}
+
+func (s *Scraper) succeed(tracker string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.failures, tracker)
+}
+
+// Run scrapes the trackers of every stored torrent, and records the health
+// reported by the best tracker of each torrent.
+// The version of the torrents is left unchanged.
+func (s *Scraper) Run() {
+	var torrents torrent.Torrents
+	if err := datastore.FetchEntities(&torrents); err != nil {
+		log.Printf("Could not fetch torrents: %s\n", err.Detail)
+		return
+	}
+	trackers := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, t := range torrents {
+		for _, tracker := range t.TrackerList() {
+			key := tracker + " " + t.InfoHash
+			if !seen[key] {
+				seen[key] = true
+				trackers[tracker] = append(trackers[tracker], t.InfoHash)
+			}
+		}
+	}
+	best := make(map[string]Stats)
+	for tracker, hashes := range trackers {
+		stats, err := s.Scrape(tracker, hashes)
+		if err == ErrBackedOff {
+			continue
+		} else if err != nil {
+			log.Printf("Could not scrape %s: %s\n", tracker, err)
+			continue
+		}
+		for hash, st := range stats {
+			b := best[hash]
+			if st.Seeders > b.Seeders {
+				b.Seeders = st.Seeders
+			}
+			if st.Leechers > b.Leechers {
+				b.Leechers = st.Leechers
+			}
+			if st.Completed > b.Completed {
+				b.Completed = st.Completed
+			}
+			best[hash] = b
+		}
+	}
+	now := s.now()
+	for hash, st := range best {
+		if err := torrent.RecordHealth(hash, st.Seeders, st.Leechers, st.Completed, now); err != nil {
+			log.Printf("Could not record the health of torrent %s: %s\n", hash, err.Detail)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	// Initialize SQLite driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/torrent-viewer/backend/datastore"
+	"github.com/torrent-viewer/backend/resources/torrent"
+)
+
+const (
+	firstHash  = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	secondHash = "0123456789abcdef0123456789abcdef01234567"
+)
+
+// mutations are the mutations published during the tests
+var mutations []datastore.Mutation
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	datastore.Init("sqlite3", "", "", "", "", "/tmp/torrent-viewer-scraper-test.db")
+	datastore.Conn.AutoMigrate(&torrent.Torrent{})
+	datastore.OnMutation(func(m datastore.Mutation) {
+		mutations = append(mutations, m)
+	})
+	ret := m.Run()
+	datastore.Conn.DropTable(&torrent.Torrent{})
+	os.Exit(ret)
+}
+
+// fakeHTTPTracker serves scrapes, reporting as many seeders for each info
+// hash as the given value, and counts the requests it receives
+func fakeHTTPTracker(seeders int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/scrape" {
+			http.NotFound(w, r)
+			return
+		}
+		body := new(bytes.Buffer)
+		body.WriteString("d5:filesd")
+		for _, raw := range r.URL.Query()["info_hash"] {
+			fmt.Fprintf(body, "%d:%sd8:completei%de10:downloadedi50e10:incompletei3ee", len(raw), raw, seeders)
+		}
+		body.WriteString("ee")
+		w.Write(body.Bytes())
+	}))
+	return server, &requests
+}
+
+// fakeUDPTracker serves the UDP tracker protocol, reporting 7 seeders,
+// 2 leechers and 70 downloads for each info hash
+func fakeUDPTracker(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const connectionID = 42
+	go func() {
+		packet := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(packet)
+			if err != nil {
+				return
+			}
+			request := packet[:n]
+			response := new(bytes.Buffer)
+			action := binary.BigEndian.Uint32(request[8:12])
+			binary.Write(response, binary.BigEndian, action)
+			response.Write(request[12:16])
+			switch {
+			case action == udpConnect && binary.BigEndian.Uint64(request[0:8]) == udpProtocolID:
+				binary.Write(response, binary.BigEndian, uint64(connectionID))
+			case action == udpScrape && binary.BigEndian.Uint64(request[0:8]) == connectionID:
+				for i := 16; i+20 <= n; i += 20 {
+					binary.Write(response, binary.BigEndian, []uint32{7, 70, 2})
+				}
+			default:
+				response.Reset()
+				binary.Write(response, binary.BigEndian, uint32(udpError))
+				response.Write(request[12:16])
+				response.WriteString("invalid request")
+			}
+			conn.WriteTo(response.Bytes(), addr)
+		}
+	}()
+	return conn
+}
+
+func TestScrapeHTTP(t *testing.T) {
+	server, requests := fakeHTTPTracker(5)
+	defer server.Close()
+	s := New(Config{BatchSize: 1})
+	stats, err := s.Scrape(server.URL+"/announce", []string{firstHash, secondHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("Expected 2 batches, got %d", *requests)
+	}
+	expected := Stats{Seeders: 5, Leechers: 3, Completed: 50}
+	if stats[firstHash] != expected || stats[secondHash] != expected {
+		t.Errorf("Expected %v for both hashes, got %v", expected, stats)
+	}
+}
+
+func TestScrapeUDP(t *testing.T) {
+	conn := fakeUDPTracker(t)
+	defer conn.Close()
+	s := New(Config{Timeout: time.Second})
+	stats, err := s.Scrape(fmt.Sprintf("udp://%s/announce", conn.LocalAddr()), []string{firstHash, secondHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{Seeders: 7, Leechers: 2, Completed: 70}
+	if stats[firstHash] != expected || stats[secondHash] != expected {
+		t.Errorf("Expected %v for both hashes, got %v", expected, stats)
+	}
+}
+
+func TestScrapeBackoff(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	now := time.Now()
+	s := New(Config{Backoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	s.now = func() time.Time { return now }
+	tracker := server.URL + "/announce"
+	for _, delay := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		if _, err := s.Scrape(tracker, []string{firstHash}); err == nil || err == ErrBackedOff {
+			t.Fatalf("Expected the tracker to fail, got %v", err)
+		}
+		now = now.Add(delay - time.Second)
+		if _, err := s.Scrape(tracker, []string{firstHash}); err != ErrBackedOff {
+			t.Fatalf("Expected the tracker to be backed off, got %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+}
+
+func TestRun(t *testing.T) {
+	first, _ := fakeHTTPTracker(5)
+	defer first.Close()
+	second, _ := fakeHTTPTracker(12)
+	defer second.Close()
+	udp := fakeUDPTracker(t)
+	defer udp.Close()
+	torrents := []*torrent.Torrent{
+		{Name: "Pilot", InfoHash: firstHash, Trackers: first.URL + "/announce," + second.URL + "/announce"},
+		{Name: "Cat's in the Bag", InfoHash: secondHash, Trackers: fmt.Sprintf("udp://%s/announce", udp.LocalAddr())},
+	}
+	for _, tr := range torrents {
+		if err := datastore.StoreEntity(tr, datastore.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mutations = nil
+	New(Config{Timeout: time.Second}).Run()
+	expected := map[string]Stats{
+		firstHash:  {Seeders: 12, Leechers: 3, Completed: 50},
+		secondHash: {Seeders: 7, Leechers: 2, Completed: 70},
+	}
+	for _, tr := range torrents {
+		var stored torrent.Torrent
+		if err := datastore.FetchEntity(&stored, tr.ID); err != nil {
+			t.Fatal(err)
+		}
+		got := Stats{Seeders: stored.Seeders, Leechers: stored.Leechers, Completed: stored.Completed}
+		if got != expected[tr.InfoHash] || stored.ScrapedAt == nil {
+			t.Errorf("Expected %v for %s, got %v scraped at %v", expected[tr.InfoHash], tr.InfoHash, got, stored.ScrapedAt)
+		}
+		if stored.Version != tr.Version {
+			t.Errorf("Expected scraping not to create a new version of %s", tr.InfoHash)
+		}
+	}
+	scraped := make(map[int]interface{})
+	for _, m := range mutations {
+		if m.Action == "scraped" && m.Type == "torrents" {
+			scraped[m.ID] = m.After["seeders"]
+		}
+	}
+	for _, tr := range torrents {
+		if scraped[tr.ID] != expected[tr.InfoHash].Seeders {
+			t.Errorf("Expected the scrape of torrent %d to be published with %d seeders, got %v", tr.ID, expected[tr.InfoHash].Seeders, scraped[tr.ID])
+		}
+	}
+}
+
+func TestDecodeBencode(t *testing.T) {
+	raw, _ := hex.DecodeString(firstHash)
+	value, err := decodeBencode([]byte(fmt.Sprintf("d5:filesd20:%sd8:completei1eee4:listli-2e3:abcee", raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := value.(map[string]interface{})
+	files := document["files"].(map[string]interface{})
+	if files[string(raw)].(map[string]interface{})["complete"] != int64(1) {
+		t.Errorf("Unexpected files %v", files)
+	}
+	if list := document["list"].([]interface{}); len(list) != 2 || list[0] != int64(-2) || list[1] != "abc" {
+		t.Errorf("Unexpected list %v", list)
+	}
+	for _, invalid := range []string{"", "i12", "5:abc", "d3:keyi1e", "le extra"} {
+		if _, err := decodeBencode([]byte(invalid)); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
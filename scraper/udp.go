@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Actions of the UDP tracker protocol (BEP 15)
+const (
+	udpConnect     = 0
+	udpScrape      = 2
+	udpError       = 3
+	udpProtocolID  = 0x41727101980
+	udpMaxHashes   = 74
+	udpMaxResponse = 8 + 12*udpMaxHashes
+)
+
+// scrapeUDP scrapes the given info hashes from a UDP tracker.
+// At most 74 info hashes can be scraped at once.
+func scrapeUDP(tracker string, hashes []string, timeout time.Duration) (map[string]Stats, error) {
+	if len(hashes) > udpMaxHashes {
+		return nil, fmt.Errorf("cannot scrape more than %d info hashes at once", udpMaxHashes)
+	}
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	request := new(bytes.Buffer)
+	transactionID, err := newTransactionID()
+	if err != nil {
+		return nil, err
+	}
+	binary.Write(request, binary.BigEndian, uint64(udpProtocolID))
+	binary.Write(request, binary.BigEndian, uint32(udpConnect))
+	binary.Write(request, binary.BigEndian, transactionID)
+	response, err := exchangeUDP(conn, request.Bytes(), udpConnect, transactionID, 16)
+	if err != nil {
+		return nil, fmt.Errorf("tracker %s: %s", tracker, err)
+	}
+	connectionID := binary.BigEndian.Uint64(response[8:16])
+
+	request.Reset()
+	if transactionID, err = newTransactionID(); err != nil {
+		return nil, err
+	}
+	binary.Write(request, binary.BigEndian, connectionID)
+	binary.Write(request, binary.BigEndian, uint32(udpScrape))
+	binary.Write(request, binary.BigEndian, transactionID)
+	for _, hash := range hashes {
+		raw, err := hex.DecodeString(hash)
+		if err != nil || len(raw) != 20 {
+			return nil, fmt.Errorf("invalid info hash %q", hash)
+		}
+		request.Write(raw)
+	}
+	response, err = exchangeUDP(conn, request.Bytes(), udpScrape, transactionID, 8+12*len(hashes))
+	if err != nil {
+		return nil, fmt.Errorf("tracker %s: %s", tracker, err)
+	}
+	stats := make(map[string]Stats)
+	for i, hash := range hashes {
+		offset := 8 + 12*i
+		stats[hash] = Stats{
+			Seeders:   int(binary.BigEndian.Uint32(response[offset:])),
+			Completed: int(binary.BigEndian.Uint32(response[offset+4:])),
+			Leechers:  int(binary.BigEndian.Uint32(response[offset+8:])),
+		}
+	}
+	return stats, nil
+}
+
+// exchangeUDP sends a request and reads its response, ensuring that it
+// answers the given action and transaction and is at least size bytes long
+func exchangeUDP(conn net.Conn, request []byte, action uint32, transactionID uint32, size int) ([]byte, error) {
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	response := make([]byte, udpMaxResponse)
+	n, err := conn.Read(response)
+	if err != nil {
+		return nil, err
+	}
+	response = response[:n]
+	if n < 8 || binary.BigEndian.Uint32(response[4:8]) != transactionID {
+		return nil, fmt.Errorf("unexpected response")
+	}
+	if got := binary.BigEndian.Uint32(response[0:4]); got == udpError {
+		return nil, fmt.Errorf("%s", response[8:])
+	} else if got != action || n < size {
+		return nil, fmt.Errorf("unexpected response")
+	}
+	return response, nil
+}
+
+func newTransactionID() (uint32, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Certificate is a TLS certificate loaded from files, which can be reloaded
+// while it is used by a server
+type Certificate struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	modified    time.Time
+}
+
+// LoadCertificate loads the PEM encoded certificate and key from the given
+// files
+func LoadCertificate(certFile string, keyFile string) (*Certificate, error) {
+	c := &Certificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the certificate and key from their files again.
+// The previous certificate is kept if they cannot be loaded.
+func (c *Certificate) Reload() error {
+	modified := c.lastModified()
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.certificate = &certificate
+	c.modified = modified
+	return nil
+}
+
+// GetCertificate returns the current certificate.
+// It is meant to be used as tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.certificate, nil
+}
+
+// lastModified returns the last time the certificate or the key changed
+func (c *Certificate) lastModified() time.Time {
+	var modified time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(name); err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified
+}
+
+// changed reports whether the files changed since they were loaded
+func (c *Certificate) changed() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.lastModified().After(c.modified)
+}
+
+// Watch reloads the certificate when the process receives SIGHUP, or when
+// its files change, which is checked every interval. It never returns.
+func (c *Certificate) Watch(interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hangup:
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+		}
+		if err := c.Reload(); err != nil {
+			log.Println("Could not reload the TLS certificate:", err)
+		} else {
+			log.Println("Reloaded the TLS certificate")
+		}
+	}
+}
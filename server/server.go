@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Config describes how the API is served.
+// HTTPS is served when CertFile and KeyFile are given, in which case
+// RedirectAddr is an optional address redirecting HTTP to HTTPS, and
+// HSTSMaxAge is the max-age of the Strict-Transport-Security header, which
+// is not sent when it is 0.
+type Config struct {
+	Addr         string
+	CertFile     string
+	KeyFile      string
+	RedirectAddr string
+	HSTSMaxAge   time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// readHeaderTimeout is the time allowed to read the headers of a request
+const readHeaderTimeout = 10 * time.Second
+
+// certificateCheck is the interval between the checks for changes of the
+// certificate files
+var certificateCheck = 10 * time.Second
+
+// TLS returns whether the configuration serves HTTPS
+func (c Config) TLS() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+func (c Config) server(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}
+}
+
+// ListenAndServe serves handler as described by config. It returns the
+// first error of its listeners.
+// With TLS, HTTP/2 is enabled and the certificate is reloaded on SIGHUP or
+// when its files change.
+func ListenAndServe(config Config, handler http.Handler) error {
+	if !config.TLS() {
+		return config.server(config.Addr, handler).ListenAndServe()
+	}
+	certificate, err := LoadCertificate(config.CertFile, config.KeyFile)
+	if err != nil {
+		return err
+	}
+	go certificate.Watch(certificateCheck)
+
+	if config.HSTSMaxAge > 0 {
+		handler = hsts(handler, config.HSTSMaxAge)
+	}
+	server := config.server(config.Addr, handler)
+	server.TLSConfig = &tls.Config{
+		GetCertificate: certificate.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	_, port, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %s", config.Addr, err)
+	}
+	errs := make(chan error, 2)
+	go func() {
+		errs <- server.ListenAndServeTLS("", "")
+	}()
+	if config.RedirectAddr != "" {
+		go func() {
+			errs <- config.server(config.RedirectAddr, redirect(port)).ListenAndServe()
+		}()
+	}
+	return <-errs
+}
+
+// hsts adds the Strict-Transport-Security header to the responses
+func hsts(handler http.Handler, maxAge time.Duration) http.Handler {
+	value := fmt.Sprintf("max-age=%d; includeSubDomains", int(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// redirect permanently redirects requests to the same URL over HTTPS, on
+// the given port
+func redirect(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
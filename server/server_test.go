@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 with the
+// given common name
+func writeCertificate(t *testing.T, certFile string, keyFile string, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, "first")
+
+	config := Config{
+		Addr:       freeAddr(t),
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		HSTSMaxAge: time.Hour,
+	}
+	go ListenAndServe(config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+			DisableKeepAlives: true,
+		},
+	}
+	var res *http.Response
+	var err error
+	for attempt := 0; attempt < 50; attempt++ {
+		if res, err = client.Get("https://" + config.Addr + "/"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2, got %s", res.Proto)
+	}
+	if hsts := res.Header.Get("Strict-Transport-Security"); hsts != "max-age=3600; includeSubDomains" {
+		t.Errorf("Unexpected Strict-Transport-Security header %q", hsts)
+	}
+	if name := res.TLS.PeerCertificates[0].Subject.CommonName; name != "first" {
+		t.Errorf("Expected certificate first, got %s", name)
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, "first")
+	certificate, err := LoadCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		c, _ := certificate.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	if err := os.WriteFile(keyFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := certificate.Reload(); err == nil {
+		t.Error("Expected an invalid key to be rejected")
+	}
+	if name := commonName(); name != "first" {
+		t.Errorf("Expected the previous certificate to be kept, got %s", name)
+	}
+
+	writeCertificate(t, certFile, keyFile, "second")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if !certificate.changed() {
+		t.Error("Expected the certificate files to have changed")
+	}
+	if err := certificate.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if name := commonName(); name != "second" {
+		t.Errorf("Expected certificate second, got %s", name)
+	}
+	if certificate.changed() {
+		t.Error("Expected the reloaded certificate to be up to date")
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		port     string
+		host     string
+		uri      string
+		location string
+	}{
+		{"443", "example.com", "/shows?page=2", "https://example.com/shows?page=2"},
+		{"8443", "example.com:8080", "/shows", "https://example.com:8443/shows"},
+		{"443", "[::1]:8080", "/", "https://[::1]/"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.uri, nil)
+		req.Host = test.host
+		w := httptest.NewRecorder()
+		redirect(test.port).ServeHTTP(w, req)
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("Expected HTTP %d, got HTTP %d", http.StatusPermanentRedirect, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("Expected a redirection to %s, got %s", test.location, location)
+		}
+	}
+}